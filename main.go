@@ -6,10 +6,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,27 +28,164 @@ func main() {
 
 	app := &webhook.App{}
 	flag.IntVar(&app.Port, "port", 9005, "port to listen")
+	flag.StringVar(&app.Config, "config", "", "yaml or json file with routes, parameters are used as defaults")
 	flag.StringVar(&app.KafkaBrokers, "kafka-brokers", "", "kafka brokers")
 	flag.StringVar(&app.KafkaGroup, "kafka-group", "", "kafka consumer group")
-	flag.StringVar(&app.KafkaTopic, "kafka-topic", "", "kafka topic")
+	flag.StringVar(&app.Filter, "filter", "", "deliver only records matching the expression, e.g. header.type == \"order\" && value.amount > 100, all if empty")
+	flag.StringVar(&app.KafkaTopic, "kafka-topic", "", "comma separated list of kafka topics")
+	flag.StringVar(&app.KafkaTopicPattern, "kafka-topic-pattern", "", "regular expression of topics to consume in addition to kafka-topic, e.g. orders\\..*")
+	flag.DurationVar(&app.TopicRefreshInterval, "kafka-topic-refresh-interval", webhook.DefaultTopicRefreshInterval, "interval new topics matching kafka-topic-pattern are looked up")
+	flag.BoolVar(&app.KafkaTLS, "kafka-tls", false, "connect to kafka brokers with tls, implied by the other kafka-tls parameters")
+	flag.StringVar(&app.KafkaTLSCA, "kafka-tls-ca", "", "pem file with ca bundle to verify kafka brokers, system roots if empty")
+	flag.StringVar(&app.KafkaTLSCert, "kafka-tls-cert", "", "pem file with client certificate for kafka brokers")
+	flag.StringVar(&app.KafkaTLSKey, "kafka-tls-key", "", "pem file with private key of the client certificate")
+	flag.StringVar(&app.KafkaTLSServerName, "kafka-tls-server-name", "", "server name verified in certificates of kafka brokers")
+	flag.BoolVar(&app.KafkaTLSInsecureSkipVerify, "kafka-tls-insecure-skip-verify", false, "do not verify certificates of kafka brokers")
+	flag.StringVar(&app.KafkaSASLMechanism, "kafka-sasl-mechanism", "", "sasl mechanism of kafka brokers: PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512, no sasl if empty")
+	flag.StringVar(&app.KafkaSASLUser, "kafka-sasl-user", "", "sasl user of kafka brokers")
+	flag.StringVar(&app.KafkaSASLPassword, "kafka-sasl-password", "", "sasl password of kafka brokers")
+	flag.StringVar(&app.StartPosition, "start-position", webhook.StartOldest, "start of partitions without committed offset: oldest, newest, timestamp=<RFC3339> or partition:offset,...")
+	flag.BoolVar(&app.ResetOffsets, "reset-offsets", false, "move committed offsets of the group to start-position and exit without delivering, stop all consumers of the group before")
+	flag.StringVar(&app.AuthType, "auth-type", "", "authentication of requests: bearer, basic, header or oauth2, none if empty")
+	flag.StringVar(&app.AuthTokenFile, "auth-token-file", "", "file with the bearer token")
+	flag.StringVar(&app.AuthUser, "auth-user", "", "user of basic auth")
+	flag.StringVar(&app.AuthPasswordFile, "auth-password-file", "", "file with the password of basic auth")
+	flag.StringVar(&app.AuthHeaderFile, "auth-header-file", "", "file with one 'Name: value' header per line added to all requests")
+	flag.StringVar(&app.AuthTokenURL, "auth-token-url", "", "token endpoint of the oauth2 client credentials flow")
+	flag.StringVar(&app.AuthClientID, "auth-client-id", "", "oauth2 client id")
+	flag.StringVar(&app.AuthClientSecretFile, "auth-client-secret-file", "", "file with the oauth2 client secret")
+	flag.StringVar(&app.AuthScopes, "auth-scopes", "", "comma separated oauth2 scopes")
+	flag.StringVar(&app.HeaderAllow, "header-allow", "", "comma separated list of record headers to send, all if empty")
+	flag.StringVar(&app.HeaderDeny, "header-deny", "", "comma separated list of record headers not to send")
+	flag.StringVar(&app.HeaderPrefix, "header-prefix", webhook.DefaultHeaderPrefix, "prefix of http headers record headers are sent as, record headers are dropped if empty")
+	flag.DurationVar(&app.HTTPDialTimeout, "http-dial-timeout", webhook.DefaultHttpDialTimeout, "timeout of connecting to a webhook")
+	flag.DurationVar(&app.HTTPTLSHandshakeTimeout, "http-tls-handshake-timeout", webhook.DefaultHttpTLSHandshakeTimeout, "timeout of the tls handshake with a webhook")
+	flag.DurationVar(&app.HTTPKeepAlive, "http-keep-alive", webhook.DefaultHttpKeepAlive, "interval of tcp keep-alive probes, disabled if negative")
+	flag.DurationVar(&app.HTTPIdleConnTimeout, "http-idle-conn-timeout", webhook.DefaultHttpIdleConnTimeout, "time idle connections are kept open, forever if zero")
+	flag.IntVar(&app.HTTPMaxIdleConnsPerHost, "http-max-idle-conns-per-host", webhook.DefaultHttpMaxIdleConnsPerHost, "idle connections kept open per host")
+	flag.StringVar(&app.HTTPProxy, "http-proxy", "", "url of the http proxy, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if empty")
+	flag.BoolVar(&app.HTTP2, "http2", true, "use http/2 with webhooks supporting it")
+	flag.StringVar(&app.HTTPTLSCA, "http-tls-ca", "", "pem file with ca bundle to verify webhooks, system roots if empty")
+	flag.StringVar(&app.HTTPTLSCert, "http-tls-cert", "", "pem file with client certificate for webhooks")
+	flag.StringVar(&app.HTTPTLSKey, "http-tls-key", "", "pem file with private key of the client certificate")
+	flag.DurationVar(&app.HTTPTLSReloadInterval, "http-tls-reload-interval", time.Minute, "interval the client certificate is reloaded, never if zero")
 	flag.StringVar(&app.HookMethod, "hook-method", http.MethodPost, "used to send data")
-	flag.StringVar(&app.HookURL, "hook-url", "", "url send data to")
+	flag.StringVar(&app.HookURL, "hook-url", "", "url send data to, may be a go template rendered per record like https://api/customers/{{.Key}}/events")
+	flag.StringVar(&app.HookAllowedHosts, "hook-allowed-hosts", "", "comma separated hosts, host:port or *.domain a hook-url template may render, the host of hook-url if empty")
+	flag.DurationVar(&app.HookTimeout, "hook-timeout", 10*time.Second, "timeout of a single delivery")
+	flag.StringVar(&app.Encoding, "encoding", webhook.EncodingDefault, "request format: default, cloudevents-binary, cloudevents-structured or standard-webhooks")
+	flag.StringVar(&app.ContentType, "content-type", "", "content type of the message values, application/octet-stream if empty")
+	flag.StringVar(&app.CloudEventsSource, "cloudevents-source", "", "source of cloudevents, /topics/<topic> if empty")
+	flag.StringVar(&app.CloudEventsType, "cloudevents-type", webhook.DefaultCloudEventsType, "type of cloudevents")
+	flag.StringVar(&app.TransformBody, "transform-body", "", "go template rendering the request body, the record value if empty")
+	app.TransformHeaders = make(map[string]string)
+	flag.Var(headerTemplates(app.TransformHeaders), "transform-header", "Name=template of an additional request header, repeatable")
+	flag.StringVar(&app.RetryBackoff, "retry-backoff", webhook.BackoffLinear, "backoff between retries: constant, linear, exponential or jitter")
+	flag.DurationVar(&app.RetryDeadline, "retry-deadline", 0, "maximum time spent retrying one message, zero retries without limit")
 	flag.DurationVar(&app.RetryDelay, "retry-delay", time.Second, "amount * attempt of time to wait between retry delivery")
 	flag.IntVar(&app.RetryLimit, "retry-limit", -1, "amount of retries before message is skip")
+	flag.DurationVar(&app.RetryMaxDelay, "retry-max-delay", 0, "maximum time to wait between retry delivery, zero for no limit")
 	flag.StringVar(&app.Secret, "secret", "", "secret used to verify message")
+	flag.StringVar(&app.SecretPath, "secret-path", "", "directory or yaml file with keys used to sign messages, replaces secret")
+	flag.DurationVar(&app.SecretReloadInterval, "secret-reload-interval", time.Minute, "interval keys of secret-path are reloaded")
+	flag.StringVar(&app.SigningKey, "signing-key", "", "pem file with ed25519 private key used to sign messages, replaces secret")
+	flag.StringVar(&app.SigningKeyID, "signing-key-id", "", "id of the signing key, jwk thumbprint if empty")
+	flag.IntVar(&app.SignatureVersion, "signature-version", webhook.SignatureV1, "signature scheme, 1 signs the body, 2 signs timestamp, delivery id and body")
+	flag.Float64Var(&app.RateLimit, "rate-limit", 0, "maximum requests per second, unlimited if zero")
+	flag.IntVar(&app.RateLimitBurst, "rate-limit-burst", 1, "requests sent at once after idle time within rate-limit")
+	flag.IntVar(&app.RateLimitBytes, "rate-limit-bytes", 0, "maximum request body bytes per second, unlimited if zero")
+	flag.StringVar(&app.RateLimitScope, "rate-limit-scope", webhook.RateLimitScopeRoute, "limits shared per route or per host of the hook-url")
+	flag.StringVar(&app.StatusRules, "status-rules", webhook.DefaultStatusRules, "classification of status codes as retry, retry-after or permanent, first match wins")
+	flag.BoolVar(&app.StrictOrder, "strict-order", false, "block a partition on a failing message instead of skipping it, never commit past undelivered messages")
+	flag.IntVar(&app.Workers, "workers", 1, "concurrent deliveries per partition, records with the same key are delivered in order")
+	flag.IntVar(&app.BatchSize, "batch-size", 0, "maximum records per request, one record per request if zero")
+	flag.IntVar(&app.BatchBytes, "batch-bytes", 0, "maximum bytes of keys and values per request, unlimited if zero")
+	flag.DurationVar(&app.BatchLinger, "batch-linger", webhook.DefaultBatchLinger, "maximum time to wait for a batch to fill")
+	flag.StringVar(&app.BatchFormat, "batch-format", webhook.BatchFormatJSON, "body of batches: json or ndjson")
+	flag.IntVar(&app.CircuitFailureThreshold, "circuit-failure-threshold", webhook.DefaultCircuitFailureThreshold, "consecutive failures of a host pausing its deliveries, disabled if zero")
+	flag.DurationVar(&app.CircuitCoolDown, "circuit-cool-down", webhook.DefaultCircuitCoolDown, "time deliveries to a failing host are paused before a probe is sent")
+	flag.StringVar(&app.DeadLetterTopic, "dead-letter-topic", "", "kafka topic undeliverable messages are sent to, skip them if empty")
 
 	_ = flag.Set("logtostderr", "true")
 	flag.Parse()
 
+	glog.V(0).Infof("Parameter AuthClientID: %s", app.AuthClientID)
+	glog.V(0).Infof("Parameter AuthClientSecretFile: %s", app.AuthClientSecretFile)
+	glog.V(0).Infof("Parameter AuthHeaderFile: %s", app.AuthHeaderFile)
+	glog.V(0).Infof("Parameter AuthPasswordFile: %s", app.AuthPasswordFile)
+	glog.V(0).Infof("Parameter AuthScopes: %s", app.AuthScopes)
+	glog.V(0).Infof("Parameter AuthTokenFile: %s", app.AuthTokenFile)
+	glog.V(0).Infof("Parameter AuthTokenURL: %s", app.AuthTokenURL)
+	glog.V(0).Infof("Parameter AuthType: %s", app.AuthType)
+	glog.V(0).Infof("Parameter AuthUser: %s", app.AuthUser)
+	glog.V(0).Infof("Parameter BatchBytes: %d", app.BatchBytes)
+	glog.V(0).Infof("Parameter BatchFormat: %s", app.BatchFormat)
+	glog.V(0).Infof("Parameter BatchLinger: %v", app.BatchLinger)
+	glog.V(0).Infof("Parameter BatchSize: %d", app.BatchSize)
+	glog.V(0).Infof("Parameter CircuitCoolDown: %v", app.CircuitCoolDown)
+	glog.V(0).Infof("Parameter CircuitFailureThreshold: %d", app.CircuitFailureThreshold)
+	glog.V(0).Infof("Parameter CloudEventsSource: %s", app.CloudEventsSource)
+	glog.V(0).Infof("Parameter CloudEventsType: %s", app.CloudEventsType)
+	glog.V(0).Infof("Parameter Config: %s", app.Config)
+	glog.V(0).Infof("Parameter ContentType: %s", app.ContentType)
+	glog.V(0).Infof("Parameter DeadLetterTopic: %s", app.DeadLetterTopic)
+	glog.V(0).Infof("Parameter Encoding: %s", app.Encoding)
+	glog.V(0).Infof("Parameter Filter: %s", app.Filter)
+	glog.V(0).Infof("Parameter HeaderAllow: %s", app.HeaderAllow)
+	glog.V(0).Infof("Parameter HeaderDeny: %s", app.HeaderDeny)
+	glog.V(0).Infof("Parameter HeaderPrefix: %s", app.HeaderPrefix)
+	glog.V(0).Infof("Parameter HTTP2: %v", app.HTTP2)
+	glog.V(0).Infof("Parameter HTTPDialTimeout: %v", app.HTTPDialTimeout)
+	glog.V(0).Infof("Parameter HTTPIdleConnTimeout: %v", app.HTTPIdleConnTimeout)
+	glog.V(0).Infof("Parameter HTTPKeepAlive: %v", app.HTTPKeepAlive)
+	glog.V(0).Infof("Parameter HTTPMaxIdleConnsPerHost: %d", app.HTTPMaxIdleConnsPerHost)
+	glog.V(0).Infof("Parameter HTTPProxy: %s", app.HTTPProxy)
+	glog.V(0).Infof("Parameter HTTPTLSCA: %s", app.HTTPTLSCA)
+	glog.V(0).Infof("Parameter HTTPTLSCert: %s", app.HTTPTLSCert)
+	glog.V(0).Infof("Parameter HTTPTLSHandshakeTimeout: %v", app.HTTPTLSHandshakeTimeout)
+	glog.V(0).Infof("Parameter HTTPTLSKey: %s", app.HTTPTLSKey)
+	glog.V(0).Infof("Parameter HTTPTLSReloadInterval: %v", app.HTTPTLSReloadInterval)
+	glog.V(0).Infof("Parameter HookAllowedHosts: %s", app.HookAllowedHosts)
 	glog.V(0).Infof("Parameter HookMethod: %s", app.HookMethod)
+	glog.V(0).Infof("Parameter HookTimeout: %v", app.HookTimeout)
 	glog.V(0).Infof("Parameter HookURL: %s", app.HookURL)
 	glog.V(0).Infof("Parameter KafkaBrokers: %s", app.KafkaBrokers)
 	glog.V(0).Infof("Parameter KafkaGroup: %s", app.KafkaGroup)
+	glog.V(0).Infof("Parameter KafkaSASLMechanism: %s", app.KafkaSASLMechanism)
+	glog.V(0).Infof("Parameter KafkaSASLPassword-Length: %d", len(app.KafkaSASLPassword))
+	glog.V(0).Infof("Parameter KafkaSASLUser: %s", app.KafkaSASLUser)
+	glog.V(0).Infof("Parameter KafkaTLS: %v", app.KafkaTLS)
+	glog.V(0).Infof("Parameter KafkaTLSCA: %s", app.KafkaTLSCA)
+	glog.V(0).Infof("Parameter KafkaTLSCert: %s", app.KafkaTLSCert)
+	glog.V(0).Infof("Parameter KafkaTLSInsecureSkipVerify: %v", app.KafkaTLSInsecureSkipVerify)
+	glog.V(0).Infof("Parameter KafkaTLSKey: %s", app.KafkaTLSKey)
+	glog.V(0).Infof("Parameter KafkaTLSServerName: %s", app.KafkaTLSServerName)
 	glog.V(0).Infof("Parameter KafkaTopic: %s", app.KafkaTopic)
+	glog.V(0).Infof("Parameter KafkaTopicPattern: %s", app.KafkaTopicPattern)
 	glog.V(0).Infof("Parameter Port: %d", app.Port)
+	glog.V(0).Infof("Parameter RateLimit: %v", app.RateLimit)
+	glog.V(0).Infof("Parameter RateLimitBurst: %d", app.RateLimitBurst)
+	glog.V(0).Infof("Parameter RateLimitBytes: %d", app.RateLimitBytes)
+	glog.V(0).Infof("Parameter RateLimitScope: %s", app.RateLimitScope)
+	glog.V(0).Infof("Parameter ResetOffsets: %v", app.ResetOffsets)
+	glog.V(0).Infof("Parameter RetryBackoff: %s", app.RetryBackoff)
+	glog.V(0).Infof("Parameter RetryDeadline: %v", app.RetryDeadline)
 	glog.V(0).Infof("Parameter RetryDelay: %v", app.RetryDelay)
 	glog.V(0).Infof("Parameter RetryLimit: %d", app.RetryLimit)
+	glog.V(0).Infof("Parameter RetryMaxDelay: %v", app.RetryMaxDelay)
 	glog.V(0).Infof("Parameter Secret-Length: %d", len(app.Secret))
+	glog.V(0).Infof("Parameter SecretPath: %s", app.SecretPath)
+	glog.V(0).Infof("Parameter SecretReloadInterval: %v", app.SecretReloadInterval)
+	glog.V(0).Infof("Parameter SigningKey: %s", app.SigningKey)
+	glog.V(0).Infof("Parameter SigningKeyID: %s", app.SigningKeyID)
+	glog.V(0).Infof("Parameter SignatureVersion: %d", app.SignatureVersion)
+	glog.V(0).Infof("Parameter StartPosition: %s", app.StartPosition)
+	glog.V(0).Infof("Parameter StatusRules: %s", app.StatusRules)
+	glog.V(0).Infof("Parameter StrictOrder: %v", app.StrictOrder)
+	glog.V(0).Infof("Parameter TopicRefreshInterval: %v", app.TopicRefreshInterval)
+	glog.V(0).Infof("Parameter TransformBody: %s", app.TransformBody)
+	glog.V(0).Infof("Parameter TransformHeaders: %v", app.TransformHeaders)
+	glog.V(0).Infof("Parameter Workers: %d", app.Workers)
 
 	err := app.Validate()
 	if err != nil {
@@ -61,6 +201,27 @@ func main() {
 	glog.V(0).Infof("app finished")
 }
 
+// headerTemplates collects repeated Name=template parameters.
+type headerTemplates map[string]string
+
+func (h headerTemplates) String() string {
+	parts := make([]string, 0, len(h))
+	for name, value := range h {
+		parts = append(parts, name+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (h headerTemplates) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return fmt.Errorf("header %s is not Name=template", value)
+	}
+	h[strings.TrimSpace(parts[0])] = parts[1]
+	return nil
+}
+
 func contextWithSig(ctx context.Context) context.Context {
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	go func() {
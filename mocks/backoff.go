@@ -0,0 +1,114 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+)
+
+type Backoff struct {
+	NextDelayStub        func(int, time.Duration) time.Duration
+	nextDelayMutex       sync.RWMutex
+	nextDelayArgsForCall []struct {
+		arg1 int
+		arg2 time.Duration
+	}
+	nextDelayReturns struct {
+		result1 time.Duration
+	}
+	nextDelayReturnsOnCall map[int]struct {
+		result1 time.Duration
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Backoff) NextDelay(arg1 int, arg2 time.Duration) time.Duration {
+	fake.nextDelayMutex.Lock()
+	ret, specificReturn := fake.nextDelayReturnsOnCall[len(fake.nextDelayArgsForCall)]
+	fake.nextDelayArgsForCall = append(fake.nextDelayArgsForCall, struct {
+		arg1 int
+		arg2 time.Duration
+	}{arg1, arg2})
+	stub := fake.NextDelayStub
+	fakeReturns := fake.nextDelayReturns
+	fake.recordInvocation("NextDelay", []interface{}{arg1, arg2})
+	fake.nextDelayMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Backoff) NextDelayCallCount() int {
+	fake.nextDelayMutex.RLock()
+	defer fake.nextDelayMutex.RUnlock()
+	return len(fake.nextDelayArgsForCall)
+}
+
+func (fake *Backoff) NextDelayCalls(stub func(int, time.Duration) time.Duration) {
+	fake.nextDelayMutex.Lock()
+	defer fake.nextDelayMutex.Unlock()
+	fake.NextDelayStub = stub
+}
+
+func (fake *Backoff) NextDelayArgsForCall(i int) (int, time.Duration) {
+	fake.nextDelayMutex.RLock()
+	defer fake.nextDelayMutex.RUnlock()
+	argsForCall := fake.nextDelayArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Backoff) NextDelayReturns(result1 time.Duration) {
+	fake.nextDelayMutex.Lock()
+	defer fake.nextDelayMutex.Unlock()
+	fake.NextDelayStub = nil
+	fake.nextDelayReturns = struct {
+		result1 time.Duration
+	}{result1}
+}
+
+func (fake *Backoff) NextDelayReturnsOnCall(i int, result1 time.Duration) {
+	fake.nextDelayMutex.Lock()
+	defer fake.nextDelayMutex.Unlock()
+	fake.NextDelayStub = nil
+	if fake.nextDelayReturnsOnCall == nil {
+		fake.nextDelayReturnsOnCall = make(map[int]struct {
+			result1 time.Duration
+		})
+	}
+	fake.nextDelayReturnsOnCall[i] = struct {
+		result1 time.Duration
+	}{result1}
+}
+
+func (fake *Backoff) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.nextDelayMutex.RLock()
+	defer fake.nextDelayMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Backoff) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ webhook.Backoff = new(Backoff)
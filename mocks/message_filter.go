@@ -0,0 +1,112 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+)
+
+type MessageFilter struct {
+	MatchStub        func(*sarama.ConsumerMessage) bool
+	matchMutex       sync.RWMutex
+	matchArgsForCall []struct {
+		arg1 *sarama.ConsumerMessage
+	}
+	matchReturns struct {
+		result1 bool
+	}
+	matchReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *MessageFilter) Match(arg1 *sarama.ConsumerMessage) bool {
+	fake.matchMutex.Lock()
+	ret, specificReturn := fake.matchReturnsOnCall[len(fake.matchArgsForCall)]
+	fake.matchArgsForCall = append(fake.matchArgsForCall, struct {
+		arg1 *sarama.ConsumerMessage
+	}{arg1})
+	stub := fake.MatchStub
+	fakeReturns := fake.matchReturns
+	fake.recordInvocation("Match", []interface{}{arg1})
+	fake.matchMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *MessageFilter) MatchCallCount() int {
+	fake.matchMutex.RLock()
+	defer fake.matchMutex.RUnlock()
+	return len(fake.matchArgsForCall)
+}
+
+func (fake *MessageFilter) MatchCalls(stub func(*sarama.ConsumerMessage) bool) {
+	fake.matchMutex.Lock()
+	defer fake.matchMutex.Unlock()
+	fake.MatchStub = stub
+}
+
+func (fake *MessageFilter) MatchArgsForCall(i int) *sarama.ConsumerMessage {
+	fake.matchMutex.RLock()
+	defer fake.matchMutex.RUnlock()
+	argsForCall := fake.matchArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *MessageFilter) MatchReturns(result1 bool) {
+	fake.matchMutex.Lock()
+	defer fake.matchMutex.Unlock()
+	fake.MatchStub = nil
+	fake.matchReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *MessageFilter) MatchReturnsOnCall(i int, result1 bool) {
+	fake.matchMutex.Lock()
+	defer fake.matchMutex.Unlock()
+	fake.MatchStub = nil
+	if fake.matchReturnsOnCall == nil {
+		fake.matchReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.matchReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *MessageFilter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.matchMutex.RLock()
+	defer fake.matchMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *MessageFilter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ webhook.MessageFilter = new(MessageFilter)
@@ -0,0 +1,115 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"sync"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+)
+
+type OffsetCommitter struct {
+	CommitOffsetsStub        func(string, string, map[int32]int64) error
+	commitOffsetsMutex       sync.RWMutex
+	commitOffsetsArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 map[int32]int64
+	}
+	commitOffsetsReturns struct {
+		result1 error
+	}
+	commitOffsetsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *OffsetCommitter) CommitOffsets(arg1 string, arg2 string, arg3 map[int32]int64) error {
+	fake.commitOffsetsMutex.Lock()
+	ret, specificReturn := fake.commitOffsetsReturnsOnCall[len(fake.commitOffsetsArgsForCall)]
+	fake.commitOffsetsArgsForCall = append(fake.commitOffsetsArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 map[int32]int64
+	}{arg1, arg2, arg3})
+	stub := fake.CommitOffsetsStub
+	fakeReturns := fake.commitOffsetsReturns
+	fake.recordInvocation("CommitOffsets", []interface{}{arg1, arg2, arg3})
+	fake.commitOffsetsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *OffsetCommitter) CommitOffsetsCallCount() int {
+	fake.commitOffsetsMutex.RLock()
+	defer fake.commitOffsetsMutex.RUnlock()
+	return len(fake.commitOffsetsArgsForCall)
+}
+
+func (fake *OffsetCommitter) CommitOffsetsCalls(stub func(string, string, map[int32]int64) error) {
+	fake.commitOffsetsMutex.Lock()
+	defer fake.commitOffsetsMutex.Unlock()
+	fake.CommitOffsetsStub = stub
+}
+
+func (fake *OffsetCommitter) CommitOffsetsArgsForCall(i int) (string, string, map[int32]int64) {
+	fake.commitOffsetsMutex.RLock()
+	defer fake.commitOffsetsMutex.RUnlock()
+	argsForCall := fake.commitOffsetsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *OffsetCommitter) CommitOffsetsReturns(result1 error) {
+	fake.commitOffsetsMutex.Lock()
+	defer fake.commitOffsetsMutex.Unlock()
+	fake.CommitOffsetsStub = nil
+	fake.commitOffsetsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *OffsetCommitter) CommitOffsetsReturnsOnCall(i int, result1 error) {
+	fake.commitOffsetsMutex.Lock()
+	defer fake.commitOffsetsMutex.Unlock()
+	fake.CommitOffsetsStub = nil
+	if fake.commitOffsetsReturnsOnCall == nil {
+		fake.commitOffsetsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.commitOffsetsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *OffsetCommitter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.commitOffsetsMutex.RLock()
+	defer fake.commitOffsetsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *OffsetCommitter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ webhook.OffsetCommitter = new(OffsetCommitter)
@@ -0,0 +1,208 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"sync"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+)
+
+type OffsetSource struct {
+	CommittedOffsetsStub        func(string, string, []int32) (map[int32]int64, error)
+	committedOffsetsMutex       sync.RWMutex
+	committedOffsetsArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 []int32
+	}
+	committedOffsetsReturns struct {
+		result1 map[int32]int64
+		result2 error
+	}
+	committedOffsetsReturnsOnCall map[int]struct {
+		result1 map[int32]int64
+		result2 error
+	}
+	GetOffsetStub        func(string, int32, int64) (int64, error)
+	getOffsetMutex       sync.RWMutex
+	getOffsetArgsForCall []struct {
+		arg1 string
+		arg2 int32
+		arg3 int64
+	}
+	getOffsetReturns struct {
+		result1 int64
+		result2 error
+	}
+	getOffsetReturnsOnCall map[int]struct {
+		result1 int64
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *OffsetSource) CommittedOffsets(arg1 string, arg2 string, arg3 []int32) (map[int32]int64, error) {
+	var arg3Copy []int32
+	if arg3 != nil {
+		arg3Copy = make([]int32, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.committedOffsetsMutex.Lock()
+	ret, specificReturn := fake.committedOffsetsReturnsOnCall[len(fake.committedOffsetsArgsForCall)]
+	fake.committedOffsetsArgsForCall = append(fake.committedOffsetsArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 []int32
+	}{arg1, arg2, arg3Copy})
+	stub := fake.CommittedOffsetsStub
+	fakeReturns := fake.committedOffsetsReturns
+	fake.recordInvocation("CommittedOffsets", []interface{}{arg1, arg2, arg3Copy})
+	fake.committedOffsetsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *OffsetSource) CommittedOffsetsCallCount() int {
+	fake.committedOffsetsMutex.RLock()
+	defer fake.committedOffsetsMutex.RUnlock()
+	return len(fake.committedOffsetsArgsForCall)
+}
+
+func (fake *OffsetSource) CommittedOffsetsCalls(stub func(string, string, []int32) (map[int32]int64, error)) {
+	fake.committedOffsetsMutex.Lock()
+	defer fake.committedOffsetsMutex.Unlock()
+	fake.CommittedOffsetsStub = stub
+}
+
+func (fake *OffsetSource) CommittedOffsetsArgsForCall(i int) (string, string, []int32) {
+	fake.committedOffsetsMutex.RLock()
+	defer fake.committedOffsetsMutex.RUnlock()
+	argsForCall := fake.committedOffsetsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *OffsetSource) CommittedOffsetsReturns(result1 map[int32]int64, result2 error) {
+	fake.committedOffsetsMutex.Lock()
+	defer fake.committedOffsetsMutex.Unlock()
+	fake.CommittedOffsetsStub = nil
+	fake.committedOffsetsReturns = struct {
+		result1 map[int32]int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *OffsetSource) CommittedOffsetsReturnsOnCall(i int, result1 map[int32]int64, result2 error) {
+	fake.committedOffsetsMutex.Lock()
+	defer fake.committedOffsetsMutex.Unlock()
+	fake.CommittedOffsetsStub = nil
+	if fake.committedOffsetsReturnsOnCall == nil {
+		fake.committedOffsetsReturnsOnCall = make(map[int]struct {
+			result1 map[int32]int64
+			result2 error
+		})
+	}
+	fake.committedOffsetsReturnsOnCall[i] = struct {
+		result1 map[int32]int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *OffsetSource) GetOffset(arg1 string, arg2 int32, arg3 int64) (int64, error) {
+	fake.getOffsetMutex.Lock()
+	ret, specificReturn := fake.getOffsetReturnsOnCall[len(fake.getOffsetArgsForCall)]
+	fake.getOffsetArgsForCall = append(fake.getOffsetArgsForCall, struct {
+		arg1 string
+		arg2 int32
+		arg3 int64
+	}{arg1, arg2, arg3})
+	stub := fake.GetOffsetStub
+	fakeReturns := fake.getOffsetReturns
+	fake.recordInvocation("GetOffset", []interface{}{arg1, arg2, arg3})
+	fake.getOffsetMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *OffsetSource) GetOffsetCallCount() int {
+	fake.getOffsetMutex.RLock()
+	defer fake.getOffsetMutex.RUnlock()
+	return len(fake.getOffsetArgsForCall)
+}
+
+func (fake *OffsetSource) GetOffsetCalls(stub func(string, int32, int64) (int64, error)) {
+	fake.getOffsetMutex.Lock()
+	defer fake.getOffsetMutex.Unlock()
+	fake.GetOffsetStub = stub
+}
+
+func (fake *OffsetSource) GetOffsetArgsForCall(i int) (string, int32, int64) {
+	fake.getOffsetMutex.RLock()
+	defer fake.getOffsetMutex.RUnlock()
+	argsForCall := fake.getOffsetArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *OffsetSource) GetOffsetReturns(result1 int64, result2 error) {
+	fake.getOffsetMutex.Lock()
+	defer fake.getOffsetMutex.Unlock()
+	fake.GetOffsetStub = nil
+	fake.getOffsetReturns = struct {
+		result1 int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *OffsetSource) GetOffsetReturnsOnCall(i int, result1 int64, result2 error) {
+	fake.getOffsetMutex.Lock()
+	defer fake.getOffsetMutex.Unlock()
+	fake.GetOffsetStub = nil
+	if fake.getOffsetReturnsOnCall == nil {
+		fake.getOffsetReturnsOnCall = make(map[int]struct {
+			result1 int64
+			result2 error
+		})
+	}
+	fake.getOffsetReturnsOnCall[i] = struct {
+		result1 int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *OffsetSource) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.committedOffsetsMutex.RLock()
+	defer fake.committedOffsetsMutex.RUnlock()
+	fake.getOffsetMutex.RLock()
+	defer fake.getOffsetMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *OffsetSource) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ webhook.OffsetSource = new(OffsetSource)
@@ -0,0 +1,117 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+)
+
+type Processor struct {
+	ProcessStub        func(context.Context, <-chan *sarama.ConsumerMessage, func(msg *sarama.ConsumerMessage)) error
+	processMutex       sync.RWMutex
+	processArgsForCall []struct {
+		arg1 context.Context
+		arg2 <-chan *sarama.ConsumerMessage
+		arg3 func(msg *sarama.ConsumerMessage)
+	}
+	processReturns struct {
+		result1 error
+	}
+	processReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Processor) Process(arg1 context.Context, arg2 <-chan *sarama.ConsumerMessage, arg3 func(msg *sarama.ConsumerMessage)) error {
+	fake.processMutex.Lock()
+	ret, specificReturn := fake.processReturnsOnCall[len(fake.processArgsForCall)]
+	fake.processArgsForCall = append(fake.processArgsForCall, struct {
+		arg1 context.Context
+		arg2 <-chan *sarama.ConsumerMessage
+		arg3 func(msg *sarama.ConsumerMessage)
+	}{arg1, arg2, arg3})
+	stub := fake.ProcessStub
+	fakeReturns := fake.processReturns
+	fake.recordInvocation("Process", []interface{}{arg1, arg2, arg3})
+	fake.processMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Processor) ProcessCallCount() int {
+	fake.processMutex.RLock()
+	defer fake.processMutex.RUnlock()
+	return len(fake.processArgsForCall)
+}
+
+func (fake *Processor) ProcessCalls(stub func(context.Context, <-chan *sarama.ConsumerMessage, func(msg *sarama.ConsumerMessage)) error) {
+	fake.processMutex.Lock()
+	defer fake.processMutex.Unlock()
+	fake.ProcessStub = stub
+}
+
+func (fake *Processor) ProcessArgsForCall(i int) (context.Context, <-chan *sarama.ConsumerMessage, func(msg *sarama.ConsumerMessage)) {
+	fake.processMutex.RLock()
+	defer fake.processMutex.RUnlock()
+	argsForCall := fake.processArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *Processor) ProcessReturns(result1 error) {
+	fake.processMutex.Lock()
+	defer fake.processMutex.Unlock()
+	fake.ProcessStub = nil
+	fake.processReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Processor) ProcessReturnsOnCall(i int, result1 error) {
+	fake.processMutex.Lock()
+	defer fake.processMutex.Unlock()
+	fake.ProcessStub = nil
+	if fake.processReturnsOnCall == nil {
+		fake.processReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.processReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Processor) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.processMutex.RLock()
+	defer fake.processMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Processor) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ webhook.Processor = new(Processor)
@@ -0,0 +1,118 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/receiver"
+)
+
+type ReceiverDecoder struct {
+	DecodeStub        func(*http.Request) (*sarama.ConsumerMessage, error)
+	decodeMutex       sync.RWMutex
+	decodeArgsForCall []struct {
+		arg1 *http.Request
+	}
+	decodeReturns struct {
+		result1 *sarama.ConsumerMessage
+		result2 error
+	}
+	decodeReturnsOnCall map[int]struct {
+		result1 *sarama.ConsumerMessage
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ReceiverDecoder) Decode(arg1 *http.Request) (*sarama.ConsumerMessage, error) {
+	fake.decodeMutex.Lock()
+	ret, specificReturn := fake.decodeReturnsOnCall[len(fake.decodeArgsForCall)]
+	fake.decodeArgsForCall = append(fake.decodeArgsForCall, struct {
+		arg1 *http.Request
+	}{arg1})
+	stub := fake.DecodeStub
+	fakeReturns := fake.decodeReturns
+	fake.recordInvocation("Decode", []interface{}{arg1})
+	fake.decodeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ReceiverDecoder) DecodeCallCount() int {
+	fake.decodeMutex.RLock()
+	defer fake.decodeMutex.RUnlock()
+	return len(fake.decodeArgsForCall)
+}
+
+func (fake *ReceiverDecoder) DecodeCalls(stub func(*http.Request) (*sarama.ConsumerMessage, error)) {
+	fake.decodeMutex.Lock()
+	defer fake.decodeMutex.Unlock()
+	fake.DecodeStub = stub
+}
+
+func (fake *ReceiverDecoder) DecodeArgsForCall(i int) *http.Request {
+	fake.decodeMutex.RLock()
+	defer fake.decodeMutex.RUnlock()
+	argsForCall := fake.decodeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *ReceiverDecoder) DecodeReturns(result1 *sarama.ConsumerMessage, result2 error) {
+	fake.decodeMutex.Lock()
+	defer fake.decodeMutex.Unlock()
+	fake.DecodeStub = nil
+	fake.decodeReturns = struct {
+		result1 *sarama.ConsumerMessage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ReceiverDecoder) DecodeReturnsOnCall(i int, result1 *sarama.ConsumerMessage, result2 error) {
+	fake.decodeMutex.Lock()
+	defer fake.decodeMutex.Unlock()
+	fake.DecodeStub = nil
+	if fake.decodeReturnsOnCall == nil {
+		fake.decodeReturnsOnCall = make(map[int]struct {
+			result1 *sarama.ConsumerMessage
+			result2 error
+		})
+	}
+	fake.decodeReturnsOnCall[i] = struct {
+		result1 *sarama.ConsumerMessage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ReceiverDecoder) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.decodeMutex.RLock()
+	defer fake.decodeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ReceiverDecoder) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ receiver.Decoder = new(ReceiverDecoder)
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	"github.com/pkg/errors"
+)
+
+// StatusError lets a callback choose the response status. The sender classifies it by its status rules.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is sent as Retry-After header if greater than zero
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %v", s.StatusCode, s.Err)
+}
+
+// Cause returns the error of the callback.
+func (s *StatusError) Cause() error {
+	return s.Err
+}
+
+// Permanent marks an error the sender must not retry, responds 422 Unprocessable Entity.
+func Permanent(err error) error {
+	return &StatusError{
+		StatusCode: http.StatusUnprocessableEntity,
+		Err:        err,
+	}
+}
+
+// RetryAfter asks the sender to retry after the given delay, responds 503 Service Unavailable.
+func RetryAfter(err error, delay time.Duration) error {
+	return &StatusError{
+		StatusCode: http.StatusServiceUnavailable,
+		RetryAfter: delay,
+		Err:        err,
+	}
+}
+
+// errBodyTooLarge is returned by reads beyond MaxBodySize.
+var errBodyTooLarge = errors.New("body too large")
+
+// statusErrorOf maps errors to the response status. Invalid signatures respond 401,
+// too large bodies 413, canceled requests 503 and all other errors the given status.
+func statusErrorOf(err error, statusCode int) *StatusError {
+	for cause := err; cause != nil; {
+		switch e := cause.(type) {
+		case *StatusError:
+			return e
+		case *webhook.SignatureError:
+			return &StatusError{StatusCode: http.StatusUnauthorized, Err: err}
+		}
+		if cause == errBodyTooLarge {
+			return &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: err}
+		}
+		if cause == context.Canceled || cause == context.DeadlineExceeded {
+			return &StatusError{StatusCode: http.StatusServiceUnavailable, Err: err}
+		}
+		causer, ok := cause.(interface {
+			Cause() error
+		})
+		if !ok {
+			break
+		}
+		cause = causer.Cause()
+	}
+	return &StatusError{StatusCode: statusCode, Err: err}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package receiver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+)
+
+// DefaultMaxBodySize is the maximum size of a request body if none is configured.
+const DefaultMaxBodySize = 10 << 20
+
+//go:generate counterfeiter -o ../mocks/receiver_decoder.go --fake-name ReceiverDecoder . Decoder
+
+// Decoder verifies and decodes a request, implemented by all codings of the webhook package.
+type Decoder interface {
+	Decode(req *http.Request) (*sarama.ConsumerMessage, error)
+}
+
+// HandlerFunc is called with every verified message. The returned error selects the response status.
+type HandlerFunc func(ctx context.Context, msg *Message) error
+
+// NewHandler returns a handler calling fn with every verified message.
+func NewHandler(decoder Decoder, fn HandlerFunc) *Handler {
+	return &Handler{
+		Decoder:     decoder,
+		HandlerFunc: fn,
+	}
+}
+
+// Middleware returns a middleware verifying every request and passing the message via context to the next handler.
+// The message is available with FromContext.
+func Middleware(decoder Decoder, maxBodySize int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return &Handler{
+			Decoder:     decoder,
+			MaxBodySize: maxBodySize,
+			Next:        next,
+		}
+	}
+}
+
+// Handler verifies and decodes webhook requests.
+// Responses map errors, so the retry logic of the sender behaves correctly:
+//
+//	200 message handled
+//	400 request not decodable
+//	401 signature invalid
+//	413 body larger than MaxBodySize
+//	422 callback returned Permanent
+//	503 callback returned RetryAfter or request canceled
+//	500 any other callback error
+type Handler struct {
+	Decoder Decoder
+	// HandlerFunc is called with every message
+	HandlerFunc HandlerFunc
+	// Next is called with the message in the request context if HandlerFunc is nil
+	Next http.Handler
+	// MaxBodySize limits the size of request bodies, DefaultMaxBodySize if zero
+	MaxBodySize int64
+}
+
+func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.ContentLength > h.maxBodySize() {
+		h.writeError(resp, &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: errBodyTooLarge})
+		return
+	}
+	req.Body = &limitedBody{
+		ReadCloser: req.Body,
+		remaining:  h.maxBodySize(),
+	}
+	msg, err := h.Decoder.Decode(req)
+	if err != nil {
+		h.writeError(resp, statusErrorOf(err, http.StatusBadRequest))
+		return
+	}
+	message := NewMessage(msg)
+	ctx := NewContext(req.Context(), message)
+	if h.HandlerFunc == nil {
+		h.Next.ServeHTTP(resp, req.WithContext(ctx))
+		return
+	}
+	if err := h.HandlerFunc(ctx, message); err != nil {
+		h.writeError(resp, statusErrorOf(err, http.StatusInternalServerError))
+		return
+	}
+	resp.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) writeError(resp http.ResponseWriter, err *StatusError) {
+	glog.V(1).Infof("handle webhook failed: %v", err)
+	if err.RetryAfter > 0 {
+		resp.Header().Set("Retry-After", strconv.FormatInt(int64((err.RetryAfter+time.Second-1)/time.Second), 10))
+	}
+	http.Error(resp, http.StatusText(err.StatusCode), err.StatusCode)
+}
+
+func (h *Handler) maxBodySize() int64 {
+	if h.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return h.MaxBodySize
+}
+
+// limitedBody fails with errBodyTooLarge if more than remaining bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
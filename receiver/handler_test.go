@@ -0,0 +1,140 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package receiver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/receiver"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var coding *webhook.RequestCoding
+	var msg *sarama.ConsumerMessage
+	var received *receiver.Message
+	var callbackErr error
+	var handler *receiver.Handler
+	BeforeEach(func() {
+		coding = &webhook.RequestCoding{
+			Url:          "http://example.com/hook",
+			Method:       http.MethodPost,
+			Signer:       &webhook.Signer{Secret: "secret"},
+			HeaderPrefix: webhook.DefaultHeaderPrefix,
+		}
+		msg = &sarama.ConsumerMessage{
+			Topic:     "orders",
+			Partition: 1,
+			Offset:    7,
+			Key:       []byte("key"),
+			Value:     []byte("value"),
+			Headers:   []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+		}
+		received = nil
+		callbackErr = nil
+		handler = receiver.NewHandler(coding, func(ctx context.Context, msg *receiver.Message) error {
+			received = msg
+			return callbackErr
+		})
+	})
+	serve := func() *httptest.ResponseRecorder {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	It("passes the verified message to the callback", func() {
+		resp := serve()
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(received).NotTo(BeNil())
+		Expect(received.Topic).To(Equal("orders"))
+		Expect(received.Partition).To(Equal(int32(1)))
+		Expect(received.Offset).To(Equal(int64(7)))
+		Expect(received.Key).To(Equal([]byte("key")))
+		Expect(received.Value).To(Equal([]byte("value")))
+		value, ok := received.Header("trace")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal([]byte("abc")))
+	})
+	It("exposes the delivery id of the request", func() {
+		coding.SignatureVersion = webhook.SignatureV2
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(received).NotTo(BeNil())
+		Expect(received.DeliveryID()).To(Equal("orders-1-7"))
+		Expect(received.DeliveryID()).To(Equal(req.Header.Get(webhook.DeliveryIdField)))
+	})
+	It("responds 401 if signature is invalid", func() {
+		handler.Decoder = &webhook.RequestCoding{Signer: &webhook.Signer{Secret: "other"}}
+		resp := serve()
+		Expect(resp.Code).To(Equal(http.StatusUnauthorized))
+		Expect(received).To(BeNil())
+	})
+	It("responds 400 if request is not decodable", func() {
+		decoder := &mocks.ReceiverDecoder{}
+		decoder.DecodeReturns(nil, errors.New("banana"))
+		handler.Decoder = decoder
+		resp := serve()
+		Expect(resp.Code).To(Equal(http.StatusBadRequest))
+	})
+	It("responds 413 if body is too large", func() {
+		handler.MaxBodySize = 4
+		resp := serve()
+		Expect(resp.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(received).To(BeNil())
+	})
+	It("responds 413 if body without content length is too large", func() {
+		handler.MaxBodySize = 4
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		req.ContentLength = -1
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+	It("responds 500 on callback error", func() {
+		callbackErr = errors.New("banana")
+		Expect(serve().Code).To(Equal(http.StatusInternalServerError))
+	})
+	It("responds 422 on permanent error", func() {
+		callbackErr = receiver.Permanent(errors.New("banana"))
+		Expect(serve().Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+	It("responds 503 with retry after", func() {
+		callbackErr = receiver.RetryAfter(errors.New("banana"), 1500*time.Millisecond)
+		resp := serve()
+		Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(resp.Header().Get("Retry-After")).To(Equal("2"))
+	})
+	It("responds 503 if canceled", func() {
+		callbackErr = context.Canceled
+		Expect(serve().Code).To(Equal(http.StatusServiceUnavailable))
+	})
+	It("passes the message via context to the next handler", func() {
+		var fromContext *receiver.Message
+		middleware := receiver.Middleware(coding, 0)
+		h := middleware(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			fromContext, _ = receiver.FromContext(req.Context())
+			resp.WriteHeader(http.StatusAccepted)
+		}))
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusAccepted))
+		Expect(fromContext).NotTo(BeNil())
+		Expect(fromContext.Value).To(Equal([]byte("value")))
+	})
+})
@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Message is a kafka record received by a webhook.
+type Message struct {
+	Topic          string
+	Partition      int32
+	Offset         int64
+	Key            []byte
+	Value          []byte
+	Headers        []Header
+	Timestamp      time.Time
+	BlockTimestamp time.Time
+}
+
+// Header is a record header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Header returns the value of the first record header with the given key.
+func (m *Message) Header(key string) ([]byte, bool) {
+	for _, header := range m.Headers {
+		if header.Key == key {
+			return header.Value, true
+		}
+	}
+	return nil, false
+}
+
+// DeliveryID returns "<topic>-<partition>-<offset>". It is the same for all deliveries of the record,
+// equals the X-Delivery-Id and CloudEvents id of the request and can be used to drop duplicates.
+func (m *Message) DeliveryID() string {
+	return fmt.Sprintf("%s-%d-%d", m.Topic, m.Partition, m.Offset)
+}
+
+// NewMessage converts a decoded sarama message.
+func NewMessage(msg *sarama.ConsumerMessage) *Message {
+	message := &Message{
+		Topic:          msg.Topic,
+		Partition:      msg.Partition,
+		Offset:         msg.Offset,
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Timestamp:      msg.Timestamp,
+		BlockTimestamp: msg.BlockTimestamp,
+	}
+	for _, header := range msg.Headers {
+		message.Headers = append(message.Headers, Header{
+			Key:   string(header.Key),
+			Value: header.Value,
+		})
+	}
+	return message
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the message.
+func NewContext(ctx context.Context, msg *Message) context.Context {
+	return context.WithValue(ctx, contextKey{}, msg)
+}
+
+// FromContext returns the message stored by the Middleware.
+func FromContext(ctx context.Context) (*Message, bool) {
+	msg, ok := ctx.Value(contextKey{}).(*Message)
+	return msg, ok
+}
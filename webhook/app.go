@@ -6,28 +6,109 @@ package webhook
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/bborbe/run"
+	runerrors "github.com/bborbe/run/errors"
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/seibert-media/go-kafka/consumer"
 )
 
 type App struct {
-	HookMethod   string
-	HookURL      string
-	KafkaBrokers string
-	KafkaGroup   string
-	KafkaTopic   string
-	Port         int
-	RetryDelay   time.Duration
-	RetryLimit   int
-	Secret       string
+	AuthClientID               string
+	AuthClientSecretFile       string
+	AuthHeaderFile             string
+	AuthPasswordFile           string
+	AuthScopes                 string
+	AuthTokenFile              string
+	AuthTokenURL               string
+	AuthType                   string
+	AuthUser                   string
+	BatchBytes                 int
+	BatchFormat                string
+	BatchLinger                time.Duration
+	BatchSize                  int
+	CircuitCoolDown            time.Duration
+	CircuitFailureThreshold    int
+	CloudEventsSource          string
+	CloudEventsType            string
+	Config                     string
+	ContentType                string
+	DeadLetterTopic            string
+	Encoding                   string
+	Filter                     string
+	HeaderAllow                string
+	HeaderDeny                 string
+	HeaderPrefix               string
+	HTTP2                      bool
+	HTTPDialTimeout            time.Duration
+	HTTPIdleConnTimeout        time.Duration
+	HTTPKeepAlive              time.Duration
+	HTTPMaxIdleConnsPerHost    int
+	HTTPProxy                  string
+	HTTPTLSCA                  string
+	HTTPTLSCert                string
+	HTTPTLSHandshakeTimeout    time.Duration
+	HTTPTLSKey                 string
+	HTTPTLSReloadInterval      time.Duration
+	HookAllowedHosts           string
+	HookMethod                 string
+	HookTimeout                time.Duration
+	HookURL                    string
+	KafkaBrokers               string
+	KafkaGroup                 string
+	KafkaSASLMechanism         string
+	KafkaSASLPassword          string
+	KafkaSASLUser              string
+	KafkaTLS                   bool
+	KafkaTLSCA                 string
+	KafkaTLSCert               string
+	KafkaTLSInsecureSkipVerify bool
+	KafkaTLSKey                string
+	KafkaTLSServerName         string
+	KafkaTopic                 string
+	KafkaTopicPattern          string
+	Port                       int
+	RateLimit                  float64
+	RateLimitBurst             int
+	RateLimitBytes             int
+	RateLimitScope             string
+	ResetOffsets               bool
+	RetryBackoff               string
+	RetryDeadline              time.Duration
+	RetryDelay                 time.Duration
+	RetryLimit                 int
+	RetryMaxDelay              time.Duration
+	Secret                     string
+	SecretPath                 string
+	SecretReloadInterval       time.Duration
+	SigningKey                 string
+	SigningKeyID               string
+	SignatureVersion           int
+	StartPosition              string
+	StatusRules                string
+	StrictOrder                bool
+	TransformBody              string
+	TransformHeaders           map[string]string
+	TopicRefreshInterval       time.Duration
+	Workers                    int
+
+	breakersOnce sync.Once
+	breakers     *CircuitBreakers
+	limitersOnce sync.Once
+	limiters     *RateLimiters
+	clientOnce   sync.Once
+	client       *ConfiguredHttpClient
+	clientErr    error
 }
 
 func (a *App) Validate() error {
@@ -37,37 +118,192 @@ func (a *App) Validate() error {
 	if a.KafkaBrokers == "" {
 		return errors.New("KafkaBrokers missing")
 	}
-	if a.KafkaTopic == "" {
-		return errors.New("KafkaTopic missing")
-	}
-	if a.KafkaGroup == "" {
-		return errors.New("KafkaGroup missing")
+	routes, err := a.Routes()
+	if err != nil {
+		return err
 	}
-	if a.HookURL == "" {
-		return errors.New("Url missing")
+	errs := a.kafkaSecurity().Validate()
+	errs = append(errs, a.httpClientConfig().Validate()...)
+	if a.CircuitFailureThreshold > 0 && a.CircuitCoolDown <= 0 {
+		errs = append(errs, errors.New("CircuitCoolDown invalid"))
 	}
-	if a.HookMethod == "" {
-		return errors.New("HookMethod missing")
+	for _, route := range routes {
+		errs = append(errs, route.validate()...)
 	}
-	if a.Secret == "" {
-		return errors.New("Secret missing")
+	if len(errs) > 0 {
+		return runerrors.New(errs...)
 	}
 	return nil
 }
 
+// Routes returns the routes of the config file or the route defined by parameters if no config file is given.
+func (a *App) Routes() ([]Route, error) {
+	if a.Config == "" {
+		return []Route{a.defaultRoute()}, nil
+	}
+	return ReadRoutes(a.Config, a.defaultRoute())
+}
+
+func (a *App) defaultRoute() Route {
+	return Route{
+		Name:                 "default",
+		KafkaTopic:           a.KafkaTopic,
+		KafkaTopicPattern:    a.KafkaTopicPattern,
+		KafkaGroup:           a.KafkaGroup,
+		Filter:               a.Filter,
+		HeaderAllow:          a.HeaderAllow,
+		HeaderDeny:           a.HeaderDeny,
+		HeaderPrefix:         a.HeaderPrefix,
+		HookMethod:           a.HookMethod,
+		HookURL:              a.HookURL,
+		HookAllowedHosts:     a.HookAllowedHosts,
+		HookTimeout:          a.HookTimeout,
+		Encoding:             a.Encoding,
+		ContentType:          a.ContentType,
+		CloudEventsSource:    a.CloudEventsSource,
+		CloudEventsType:      a.CloudEventsType,
+		TransformBody:        a.TransformBody,
+		TransformHeaders:     a.TransformHeaders,
+		RetryBackoff:         a.RetryBackoff,
+		RetryDeadline:        a.RetryDeadline,
+		RetryDelay:           a.RetryDelay,
+		RetryLimit:           a.RetryLimit,
+		RetryMaxDelay:        a.RetryMaxDelay,
+		Secret:               a.Secret,
+		SecretPath:           a.SecretPath,
+		SigningKey:           a.SigningKey,
+		SigningKeyID:         a.SigningKeyID,
+		SignatureVersion:     a.SignatureVersion,
+		AuthType:             a.AuthType,
+		AuthTokenFile:        a.AuthTokenFile,
+		AuthUser:             a.AuthUser,
+		AuthPasswordFile:     a.AuthPasswordFile,
+		AuthHeaderFile:       a.AuthHeaderFile,
+		AuthTokenURL:         a.AuthTokenURL,
+		AuthClientID:         a.AuthClientID,
+		AuthClientSecretFile: a.AuthClientSecretFile,
+		AuthScopes:           a.AuthScopes,
+		StatusRules:          a.StatusRules,
+		RateLimit:            a.RateLimit,
+		RateLimitBurst:       a.RateLimitBurst,
+		RateLimitBytes:       a.RateLimitBytes,
+		RateLimitScope:       a.RateLimitScope,
+		StrictOrder:          a.StrictOrder,
+		Workers:              a.Workers,
+		StartPosition:        a.StartPosition,
+		ResetOffsets:         a.ResetOffsets,
+		BatchSize:            a.BatchSize,
+		BatchBytes:           a.BatchBytes,
+		BatchLinger:          a.BatchLinger,
+		BatchFormat:          a.BatchFormat,
+		DeadLetterTopic:      a.DeadLetterTopic,
+	}
+}
+
+// httpClientConfig returns the settings of the connections to the webhooks.
+func (a *App) httpClientConfig() *HttpClientConfig {
+	return &HttpClientConfig{
+		DialTimeout:         a.HTTPDialTimeout,
+		TLSHandshakeTimeout: a.HTTPTLSHandshakeTimeout,
+		KeepAlive:           a.HTTPKeepAlive,
+		IdleConnTimeout:     a.HTTPIdleConnTimeout,
+		MaxIdleConnsPerHost: a.HTTPMaxIdleConnsPerHost,
+		Proxy:               a.HTTPProxy,
+		HTTP2:               a.HTTP2,
+		TLSCA:               a.HTTPTLSCA,
+		TLSCert:             a.HTTPTLSCert,
+		TLSKey:              a.HTTPTLSKey,
+		TLSReloadInterval:   a.HTTPTLSReloadInterval,
+	}
+}
+
+// httpClient returns the client shared by all routes.
+func (a *App) httpClient() (*ConfiguredHttpClient, error) {
+	a.clientOnce.Do(func() {
+		a.client, a.clientErr = NewHttpClient(a.httpClientConfig())
+	})
+	return a.client, a.clientErr
+}
+
+// kafkaSecurity returns the TLS and SASL settings of all kafka connections.
+func (a *App) kafkaSecurity() *KafkaSecurity {
+	return &KafkaSecurity{
+		TLS:                   a.KafkaTLS,
+		TLSCA:                 a.KafkaTLSCA,
+		TLSCert:               a.KafkaTLSCert,
+		TLSKey:                a.KafkaTLSKey,
+		TLSServerName:         a.KafkaTLSServerName,
+		TLSInsecureSkipVerify: a.KafkaTLSInsecureSkipVerify,
+		SASLMechanism:         a.KafkaSASLMechanism,
+		SASLUser:              a.KafkaSASLUser,
+		SASLPassword:          a.KafkaSASLPassword,
+	}
+}
+
 func (a *App) Run(ctx context.Context) error {
+	routes, err := a.Routes()
+	if err != nil {
+		return err
+	}
+	for _, route := range routes {
+		if route.ResetOffsets {
+			return a.RunResetOffsets(ctx, routes)
+		}
+	}
+	httpClient, err := a.httpClient()
+	if err != nil {
+		return err
+	}
+	runners := []run.RunFunc{a.RunServer, httpClient.Run}
+	for _, route := range routes {
+		route := route
+		selector, err := NewTopicSelector(route.KafkaTopic, route.KafkaTopicPattern)
+		if err != nil {
+			return err
+		}
+		glog.V(0).Infof("route %s: deliver topic %s to %s %s", route.Name, selector, route.HookMethod, route.HookURL)
+		runners = append(runners, func(ctx context.Context) error {
+			return a.RunConsumer(ctx, route)
+		})
+	}
+	return run.CancelOnFirstFinish(ctx, runners...)
+}
 
-	return run.CancelOnFirstFinish(ctx, a.RunConsumer, a.RunServer)
+// RunResetOffsets moves the committed offsets of all routes with ResetOffsets to their start position and returns
+// without delivering, so the reset happens once per group instead of in every consumer.
+func (a *App) RunResetOffsets(ctx context.Context, routes []Route) error {
+	for _, route := range routes {
+		if !route.ResetOffsets {
+			continue
+		}
+		startPosition, err := ParseStartPosition(route.StartPosition)
+		if err != nil {
+			return err
+		}
+		consumer := &GroupConsumer{
+			KafkaBrokers:      a.KafkaBrokers,
+			KafkaTopic:        route.KafkaTopic,
+			KafkaTopicPattern: route.KafkaTopicPattern,
+			KafkaGroup:        route.KafkaGroup,
+			Security:          a.kafkaSecurity(),
+			StartPosition:     startPosition,
+		}
+		if err := consumer.ResetOffsets(ctx); err != nil {
+			return errors.Wrapf(err, "route %s: reset offsets failed", route.Name)
+		}
+		glog.V(0).Infof("route %s: offsets of group %s reset", route.Name, route.KafkaGroup)
+	}
+	return nil
 }
 
 func (a *App) RunServer(ctx context.Context) error {
-	router := mux.NewRouter()
-	router.Path("/healthz").HandlerFunc(a.HealthCheck)
-	router.Path("/readiness").HandlerFunc(a.ReadinessCheck)
-	router.Path("/metrics").Handler(promhttp.Handler())
+	handler, err := a.Handler()
+	if err != nil {
+		return err
+	}
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", a.Port),
-		Handler: router,
+		Handler: handler,
 	}
 	go func() {
 		select {
@@ -80,30 +316,373 @@ func (a *App) RunServer(ctx context.Context) error {
 	return server.ListenAndServe()
 }
 
-func (a *App) RunConsumer(ctx context.Context) error {
-	consumer := &consumer.OffsetConsumer{
-		KafkaBrokers: a.KafkaBrokers,
-		KafkaTopic:   a.KafkaTopic,
-		KafkaGroup:   a.KafkaGroup,
-		MessageHandler: &RetryMessageHandler{
-			MaxRetry:           a.RetryLimit,
-			WaitBetweenRetries: a.RetryDelay,
-			MessageHandler: &PostMessageHandler{
-				Timeout: 10 * time.Second,
-				RequestBuilder: &RequestCoding{
-					Url:    a.HookURL,
-					Method: a.HookMethod,
-					Signer: &Signer{
-						Secret: a.Secret,
-					},
-				},
-				HttpClient: &HttpClientMetrics{
-					HttpClient: http.DefaultClient,
-				},
+// Handler returns the HTTP handler of the server with the public keys, health, readiness and metrics.
+func (a *App) Handler() (http.Handler, error) {
+	jwks, err := a.jwks()
+	if err != nil {
+		return nil, err
+	}
+	router := mux.NewRouter()
+	router.Path(JWKSPath).Handler(jwks)
+	router.Path("/healthz").HandlerFunc(a.HealthCheck)
+	router.Path("/readiness").HandlerFunc(a.ReadinessCheck)
+	router.Path("/metrics").Handler(promhttp.Handler())
+	return router, nil
+}
+
+// RunConsumer delivers all records of the route's topic to its webhook or to all its destinations.
+func (a *App) RunConsumer(ctx context.Context, route Route) error {
+	startPosition, err := ParseStartPosition(route.StartPosition)
+	if err != nil {
+		return err
+	}
+	var runners []run.RunFunc
+	var processor Processor
+	if len(route.Destinations) == 0 {
+		partitionProcessor, destinationRunners, closers, err := a.createProcessor(route)
+		defer closeAll(closers)
+		if err != nil {
+			return err
+		}
+		runners = append(runners, destinationRunners...)
+		processor = partitionProcessor
+	} else {
+		fanOut := &FanOutProcessor{}
+		for _, destination := range route.Destinations {
+			glog.V(0).Infof("route %s: deliver to %s %s", destination.Name, destination.HookMethod, destination.HookURL)
+			partitionProcessor, destinationRunners, closers, err := a.createProcessor(destination)
+			defer closeAll(closers)
+			if err != nil {
+				return err
+			}
+			runners = append(runners, destinationRunners...)
+			fanOut.Processors = append(fanOut.Processors, partitionProcessor)
+		}
+		processor = fanOut
+	}
+	consumer := &GroupConsumer{
+		KafkaBrokers:         a.KafkaBrokers,
+		KafkaTopic:           route.KafkaTopic,
+		KafkaTopicPattern:    route.KafkaTopicPattern,
+		TopicRefreshInterval: a.TopicRefreshInterval,
+		KafkaGroup:           route.KafkaGroup,
+		Security:             a.kafkaSecurity(),
+		StartPosition:        startPosition,
+		Processor:            processor,
+	}
+	runners = append(runners, consumer.Consume)
+	return run.CancelOnFirstFinish(ctx, runners...)
+}
+
+// createProcessor returns the processor delivering to the webhook of the route,
+// the functions to run in background and the resources to close afterwards.
+func (a *App) createProcessor(route Route) (*PartitionProcessor, []run.RunFunc, []io.Closer, error) {
+	var runners []run.RunFunc
+	var closers []io.Closer
+	signer, signerRunner, err := a.createSigner(route)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if signerRunner != nil {
+		runners = append(runners, signerRunner)
+	}
+	statusRules, err := ParseStatusRules(route.StatusRules)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	backoff, err := NewBackoff(route.RetryBackoff, route.RetryDelay, route.RetryMaxDelay)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	filter, err := ParseFilter(route.Filter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	transform, err := NewTransform(route.TransformBody, route.TransformHeaders)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var urlTemplate *URLTemplate
+	if IsURLTemplate(route.HookURL) {
+		if urlTemplate, err = NewURLTemplate(route.HookURL, route.HookAllowedHosts); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	client, err := a.httpClient()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var httpClient HttpClient = &HttpClientMetrics{
+		HttpClient: client,
+	}
+	// token requests bypass the circuit breaker, it only sees requests to the hook host
+	authenticator, err := NewAuthenticator(route.authConfig(), httpClient)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if a.CircuitFailureThreshold > 0 {
+		httpClient = &CircuitBreakerHttpClient{
+			HttpClient: httpClient,
+			Breakers:   a.circuitBreakers(),
+		}
+	}
+	if authenticator != nil {
+		httpClient = &AuthHttpClient{
+			HttpClient:    httpClient,
+			Authenticator: authenticator,
+		}
+	}
+	limiter := a.createLimiter(route)
+	retryHandler := &RetryMessageHandler{
+		MaxRetry:    route.RetryLimit,
+		Backoff:     backoff,
+		MaxDelay:    route.RetryMaxDelay,
+		Deadline:    route.RetryDeadline,
+		StatusRules: statusRules,
+	}
+	if route.BatchSize > 0 {
+		retryHandler.BatchHandler = &PostBatchHandler{
+			Timeout: route.HookTimeout,
+			RequestBuilder: &BatchCoding{
+				Url:              route.HookURL,
+				Method:           route.HookMethod,
+				Format:           route.BatchFormat,
+				Signer:           signer,
+				HeaderFilter:     NewHeaderFilter(route.HeaderAllow, route.HeaderDeny),
+				SignatureVersion: route.SignatureVersion,
 			},
-		},
+			HttpClient: httpClient,
+			Limiter:    limiter,
+		}
+	} else {
+		coderRoute := route
+		if urlTemplate != nil {
+			coderRoute.HookURL = urlTemplatePlaceholder
+		}
+		postHandler := &PostMessageHandler{
+			Timeout:        route.HookTimeout,
+			RequestBuilder: a.createRequestCoder(coderRoute, signer),
+			HttpClient:     httpClient,
+			Limiter:        limiter,
+		}
+		if transform != nil {
+			postHandler.RequestBuilder = &TransformRequestBuilder{
+				Transform:      transform,
+				ContentType:    route.ContentType,
+				RequestBuilder: postHandler.RequestBuilder,
+			}
+		}
+		if urlTemplate != nil {
+			// rendered from the record, not the transformed body
+			postHandler.RequestBuilder = &URLRequestBuilder{
+				URL:            urlTemplate,
+				RequestBuilder: postHandler.RequestBuilder,
+			}
+		}
+		retryHandler.MessageHandler = postHandler
+	}
+	var handler interface {
+		MessageHandler
+		BatchMessageHandler
+	} = &MetricsMessageHandler{
+		Route:          route.Name,
+		MessageHandler: retryHandler,
+		BatchHandler:   retryHandler,
+	}
+	if route.DeadLetterTopic != "" {
+		producer, err := a.createSyncProducer()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		closers = append(closers, producer)
+		handler = &DeadLetterMessageHandler{
+			MessageHandler: handler,
+			BatchHandler:   handler,
+			Producer:       producer,
+			Topic:          route.DeadLetterTopic,
+		}
+	}
+	processor := &PartitionProcessor{
+		MessageHandler: handler,
+		Strict:         route.StrictOrder,
+		Backoff:        backoff,
+		Workers:        route.Workers,
+		BatchSize:      route.BatchSize,
+		BatchBytes:     route.BatchBytes,
+		BatchLinger:    route.BatchLinger,
+	}
+	if filter != nil {
+		processor.Filter = &RouteFilter{
+			Route:  route.Name,
+			Filter: filter,
+		}
+	}
+	if route.BatchSize > 0 {
+		processor.BatchHandler = handler
+	}
+	return processor, runners, closers, nil
+}
+
+// createLimiter returns the rate limiter of the route or nil if unlimited.
+func (a *App) createLimiter(route Route) RequestLimiter {
+	limit := RateLimit{
+		Requests: route.RateLimit,
+		Burst:    route.RateLimitBurst,
+		Bytes:    route.RateLimitBytes,
+	}
+	if !limit.Enabled() {
+		return nil
+	}
+	if route.RateLimitScope == RateLimitScopeHost {
+		return &HostRateLimiter{
+			Limiters: a.rateLimiters(),
+			Limit:    limit,
+		}
+	}
+	return &RateLimiter{
+		Limit: limit,
+	}
+}
+
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			glog.Warningf("close failed: %v", err)
+		}
+	}
+}
+
+// createRequestCoder returns the coder of the route's encoding.
+func (a *App) createRequestCoder(route Route, signer RequestSigner) RequestCoder {
+	headerFilter := NewHeaderFilter(route.HeaderAllow, route.HeaderDeny)
+	switch route.Encoding {
+	case EncodingCloudEventsBinary:
+		return &CloudEventsBinaryCoding{
+			Url:              route.HookURL,
+			Method:           route.HookMethod,
+			Source:           route.CloudEventsSource,
+			Type:             route.CloudEventsType,
+			ContentType:      route.ContentType,
+			Signer:           signer,
+			HeaderPrefix:     route.HeaderPrefix,
+			HeaderFilter:     headerFilter,
+			SignatureVersion: route.SignatureVersion,
+		}
+	case EncodingCloudEventsStructured:
+		return &CloudEventsStructuredCoding{
+			Url:              route.HookURL,
+			Method:           route.HookMethod,
+			Source:           route.CloudEventsSource,
+			Type:             route.CloudEventsType,
+			ContentType:      route.ContentType,
+			Signer:           signer,
+			RecordHeaders:    route.HeaderPrefix != "",
+			HeaderFilter:     headerFilter,
+			SignatureVersion: route.SignatureVersion,
+		}
+	case EncodingStandardWebhooks:
+		return &StandardWebhooksCoding{
+			Url:          route.HookURL,
+			Method:       route.HookMethod,
+			ContentType:  route.ContentType,
+			Secret:       route.Secret,
+			HeaderPrefix: route.HeaderPrefix,
+			HeaderFilter: headerFilter,
+		}
+	default:
+		return &RequestCoding{
+			Url:              route.HookURL,
+			Method:           route.HookMethod,
+			Signer:           signer,
+			HeaderPrefix:     route.HeaderPrefix,
+			HeaderFilter:     headerFilter,
+			SignatureVersion: route.SignatureVersion,
+		}
+	}
+}
+
+// createSigner returns the signer of the route and a function to run in background if needed.
+func (a *App) createSigner(route Route) (RequestSigner, run.RunFunc, error) {
+	if route.SigningKey != "" {
+		signer, err := a.createEd25519Signer(route)
+		return signer, nil, err
+	}
+	if route.SecretPath != "" {
+		loader := &KeySetLoader{
+			Path:     route.SecretPath,
+			KeySet:   &KeySet{},
+			Interval: a.SecretReloadInterval,
+		}
+		if err := loader.Load(); err != nil {
+			return nil, nil, err
+		}
+		return loader.KeySet, loader.Run, nil
+	}
+	return &Signer{
+		Secret: route.Secret,
+	}, nil, nil
+}
+
+func (a *App) createEd25519Signer(route Route) (*Ed25519Signer, error) {
+	privateKey, err := ReadEd25519PrivateKey(route.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	keyID := route.SigningKeyID
+	if keyID == "" {
+		keyID = Ed25519Thumbprint(privateKey.Public().(ed25519.PublicKey))
+	}
+	return &Ed25519Signer{
+		KeyID:      keyID,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// jwks returns the public keys of all routes and destinations signing with a private key.
+func (a *App) jwks() (*JWKS, error) {
+	routes, err := a.Routes()
+	if err != nil {
+		return nil, err
+	}
+	jwks := &JWKS{
+		Keys: []JWK{},
 	}
-	return consumer.Consume(ctx)
+	var signingRoutes []Route
+	for _, route := range routes {
+		if len(route.Destinations) == 0 {
+			signingRoutes = append(signingRoutes, route)
+		} else {
+			signingRoutes = append(signingRoutes, route.Destinations...)
+		}
+	}
+	for _, route := range signingRoutes {
+		if route.SigningKey == "" {
+			continue
+		}
+		signer, err := a.createEd25519Signer(route)
+		if err != nil {
+			return nil, err
+		}
+		if err := jwks.Add(NewEd25519JWK(signer.KeyID, signer.PrivateKey.Public().(ed25519.PublicKey))); err != nil {
+			return nil, errors.Wrapf(err, "route %s", route.Name)
+		}
+	}
+	return jwks, nil
+}
+
+func (a *App) createSyncProducer() (sarama.SyncProducer, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_0_0_0
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	if err := a.kafkaSecurity().Apply(config); err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducer(strings.Split(a.KafkaBrokers, ","), config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create producer with brokers %s failed", a.KafkaBrokers)
+	}
+	return producer, nil
 }
 
 func (a *App) HealthCheck(resp http.ResponseWriter, req *http.Request) {
@@ -111,7 +690,32 @@ func (a *App) HealthCheck(resp http.ResponseWriter, req *http.Request) {
 	fmt.Fprintf(resp, "ok")
 }
 
+// circuitBreakers returns the circuit breakers of all destination hosts.
+func (a *App) circuitBreakers() *CircuitBreakers {
+	a.breakersOnce.Do(func() {
+		a.breakers = &CircuitBreakers{
+			FailureThreshold: a.CircuitFailureThreshold,
+			CoolDown:         a.CircuitCoolDown,
+		}
+	})
+	return a.breakers
+}
+
+// rateLimiters returns the rate limiters shared by all routes sending to a host.
+func (a *App) rateLimiters() *RateLimiters {
+	a.limitersOnce.Do(func() {
+		a.limiters = &RateLimiters{}
+	})
+	return a.limiters
+}
+
+// ReadinessCheck fails while the circuit of a destination host is not closed.
 func (a *App) ReadinessCheck(resp http.ResponseWriter, req *http.Request) {
+	if hosts := a.circuitBreakers().Tripped(); len(hosts) > 0 {
+		resp.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(resp, "circuit open: %s", strings.Join(hosts, ","))
+		return
+	}
 	resp.WriteHeader(200)
 	fmt.Fprintf(resp, "ok")
 }
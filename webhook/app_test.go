@@ -5,7 +5,17 @@
 package webhook_test
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
 
 	"github.com/bborbe/kafka-webhook/webhook"
 	. "github.com/onsi/ginkgo"
@@ -22,12 +32,22 @@ var _ = Describe("Webhook App", func() {
 			KafkaGroup:   "my-group",
 			HookURL:      "http://www.example.com",
 			HookMethod:   http.MethodPost,
+			HookTimeout:  10 * time.Second,
 			Secret:       "secret",
 		}
 	})
 	It("Validate without error", func() {
 		Expect(app.Validate()).NotTo(HaveOccurred())
 	})
+	It("Validate returns error if circuit cool-down is missing", func() {
+		app.CircuitFailureThreshold = 5
+		Expect(app.Validate()).To(HaveOccurred())
+	})
+	It("reports ready without open circuits", func() {
+		recorder := httptest.NewRecorder()
+		app.ReadinessCheck(recorder, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
 	It("Validate returns error if port is 0", func() {
 		app.Port = 0
 		Expect(app.Validate()).To(HaveOccurred())
@@ -52,4 +72,102 @@ var _ = Describe("Webhook App", func() {
 		app.Secret = ""
 		Expect(app.Validate()).To(HaveOccurred())
 	})
+	It("Validate returns error HookTimeout is 0", func() {
+		app.HookTimeout = 0
+		Expect(app.Validate()).To(HaveOccurred())
+	})
+	It("Validate returns error if Encoding is unknown", func() {
+		app.Encoding = "soap"
+		Expect(app.Validate()).To(HaveOccurred())
+	})
+	It("Validate returns error if KafkaSASLMechanism is not supported", func() {
+		app.KafkaSASLMechanism = "GSSAPI"
+		app.KafkaSASLUser = "user"
+		Expect(app.Validate()).To(HaveOccurred())
+	})
+	Context("with config", func() {
+		var config *os.File
+		BeforeEach(func() {
+			var err error
+			config, err = ioutil.TempFile("", "routes")
+			Expect(err).To(BeNil())
+			app.Config = config.Name()
+		})
+		AfterEach(func() {
+			_ = os.Remove(config.Name())
+		})
+		It("Validate without error", func() {
+			_, err := config.WriteString(`
+routes:
+- name: orders
+  kafka-topic: orders
+- name: invoices
+  kafka-topic: invoices
+  hook-url: http://invoices.example.com
+`)
+			Expect(err).To(BeNil())
+			Expect(app.Validate()).NotTo(HaveOccurred())
+		})
+		It("Validate returns errors of all routes", func() {
+			_, err := config.WriteString(`
+routes:
+- name: orders
+  kafka-topic: ""
+- name: invoices
+  hook-url: ""
+`)
+			Expect(err).To(BeNil())
+			err = app.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("route orders: KafkaTopic missing"))
+			Expect(err.Error()).To(ContainSubstring("route invoices: Url missing"))
+		})
+		It("publishes the keys of destinations signing with a private key", func() {
+			writeKey := func() string {
+				_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+				Expect(err).To(BeNil())
+				der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+				Expect(err).To(BeNil())
+				file, err := ioutil.TempFile("", "key")
+				Expect(err).To(BeNil())
+				Expect(pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der})).To(BeNil())
+				Expect(file.Close()).To(BeNil())
+				return file.Name()
+			}
+			crmKey, billingKey := writeKey(), writeKey()
+			defer os.Remove(crmKey)
+			defer os.Remove(billingKey)
+			_, err := config.WriteString(fmt.Sprintf(`
+routes:
+- name: orders
+  kafka-topic: orders
+  destinations:
+  - name: crm
+    hook-url: http://crm.example.com/hook
+    signing-key: %s
+    signing-key-id: crm-key
+  - name: billing
+    hook-url: http://billing.example.com/hook
+    signing-key: %s
+    signing-key-id: billing-key
+`, crmKey, billingKey))
+			Expect(err).To(BeNil())
+			handler, err := app.Handler()
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, webhook.JWKSPath, nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var jwks webhook.JWKS
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &jwks)).To(BeNil())
+			var kids []string
+			for _, jwk := range jwks.Keys {
+				kids = append(kids, jwk.Kid)
+			}
+			Expect(kids).To(ConsistOf("crm-key", "billing-key"))
+		})
+		It("Validate returns error if config not exists", func() {
+			app.Config = "/not/existing.yaml"
+			Expect(app.Validate()).To(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,374 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Types of the available authenticators.
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthHeader = "header"
+	AuthOAuth2 = "oauth2"
+)
+
+// maxOAuth2RefreshBefore is the longest time a token is refreshed before it expires.
+const maxOAuth2RefreshBefore = time.Minute
+
+//go:generate counterfeiter -o ../mocks/authenticator.go --fake-name Authenticator . Authenticator
+
+// Authenticator adds credentials to outgoing requests.
+type Authenticator interface {
+	// Authenticate sets the credentials headers of the request.
+	Authenticate(req *http.Request) error
+	// Invalidate drops cached credentials after the receiver rejected them.
+	Invalidate()
+}
+
+// AuthConfig selects and configures an Authenticator. Secrets are read from files, so they can be
+// rotated through Kubernetes secret mounts without restart.
+type AuthConfig struct {
+	// Type is bearer, basic, header or oauth2, no authentication if empty
+	Type string
+	// TokenFile contains the bearer token
+	TokenFile string
+	// User and PasswordFile are the basic auth credentials
+	User         string
+	PasswordFile string
+	// HeaderFile contains one "Name: value" header per line
+	HeaderFile string
+	// TokenURL, ClientID, ClientSecretFile and Scopes configure the OAuth2 client credentials flow
+	TokenURL         string
+	ClientID         string
+	ClientSecretFile string
+	Scopes           string
+}
+
+// Validate returns all problems of the settings at once.
+func (a *AuthConfig) Validate() []error {
+	var errs []error
+	switch a.Type {
+	case "":
+	case AuthBearer:
+		if a.TokenFile == "" {
+			errs = append(errs, errors.New("AuthTokenFile missing"))
+		}
+	case AuthBasic:
+		if a.User == "" {
+			errs = append(errs, errors.New("AuthUser missing"))
+		}
+		if a.PasswordFile == "" {
+			errs = append(errs, errors.New("AuthPasswordFile missing"))
+		}
+	case AuthHeader:
+		if a.HeaderFile == "" {
+			errs = append(errs, errors.New("AuthHeaderFile missing"))
+		}
+	case AuthOAuth2:
+		if u, err := url.Parse(a.TokenURL); err != nil || u.Host == "" {
+			errs = append(errs, errors.Errorf("AuthTokenURL %s invalid", a.TokenURL))
+		}
+		if a.ClientID == "" {
+			errs = append(errs, errors.New("AuthClientID missing"))
+		}
+		if a.ClientSecretFile == "" {
+			errs = append(errs, errors.New("AuthClientSecretFile missing"))
+		}
+	default:
+		errs = append(errs, errors.Errorf("AuthType %s unknown", a.Type))
+	}
+	return errs
+}
+
+// NewAuthenticator returns the Authenticator of the config or nil if no type is given.
+// The OAuth2 token endpoint is called with the httpClient.
+func NewAuthenticator(config *AuthConfig, httpClient HttpClient) (Authenticator, error) {
+	if errs := config.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	switch config.Type {
+	case AuthBearer:
+		return &BearerAuthenticator{
+			Token: &SecretFile{Path: config.TokenFile},
+		}, nil
+	case AuthBasic:
+		return &BasicAuthenticator{
+			User:     config.User,
+			Password: &SecretFile{Path: config.PasswordFile},
+		}, nil
+	case AuthHeader:
+		return &HeaderAuthenticator{
+			Headers: &SecretFile{Path: config.HeaderFile},
+		}, nil
+	case AuthOAuth2:
+		return &OAuth2Authenticator{
+			TokenURL:     config.TokenURL,
+			ClientID:     config.ClientID,
+			ClientSecret: &SecretFile{Path: config.ClientSecretFile},
+			Scopes:       strings.Fields(strings.Replace(config.Scopes, ",", " ", -1)),
+			HttpClient:   httpClient,
+		}, nil
+	}
+	return nil, nil
+}
+
+// SecretFile reads a secret from a file. The content is cached until the modification time of the file changes.
+type SecretFile struct {
+	Path string
+
+	mux     sync.Mutex
+	value   string
+	modTime time.Time
+	loaded  bool
+}
+
+// Read returns the content of the file without surrounding whitespace.
+func (s *SecretFile) Read() (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return "", errors.Wrapf(err, "stat secret %s failed", s.Path)
+	}
+	if s.loaded && info.ModTime().Equal(s.modTime) {
+		return s.value, nil
+	}
+	content, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return "", errors.Wrapf(err, "read secret %s failed", s.Path)
+	}
+	s.value = strings.TrimSpace(string(content))
+	s.modTime = info.ModTime()
+	s.loaded = true
+	return s.value, nil
+}
+
+// Invalidate reads the file again on the next Read.
+func (s *SecretFile) Invalidate() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.loaded = false
+}
+
+// BearerAuthenticator sends a static token as bearer.
+type BearerAuthenticator struct {
+	Token *SecretFile
+}
+
+func (b *BearerAuthenticator) Authenticate(req *http.Request) error {
+	token, err := b.Token.Read()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (b *BearerAuthenticator) Invalidate() {
+	b.Token.Invalidate()
+}
+
+// BasicAuthenticator sends user and password as basic auth.
+type BasicAuthenticator struct {
+	User     string
+	Password *SecretFile
+}
+
+func (b *BasicAuthenticator) Authenticate(req *http.Request) error {
+	password, err := b.Password.Read()
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(b.User, password)
+	return nil
+}
+
+func (b *BasicAuthenticator) Invalidate() {
+	b.Password.Invalidate()
+}
+
+// HeaderAuthenticator sends the headers of a file with one "Name: value" per line, empty lines and lines starting with # are ignored.
+type HeaderAuthenticator struct {
+	Headers *SecretFile
+}
+
+func (h *HeaderAuthenticator) Authenticate(req *http.Request) error {
+	content, err := h.Headers.Read()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return errors.Errorf("invalid header line in %s", h.Headers.Path)
+		}
+		req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return nil
+}
+
+func (h *HeaderAuthenticator) Invalidate() {
+	h.Headers.Invalidate()
+}
+
+// OAuth2Authenticator sends a bearer token fetched with the OAuth2 client credentials grant.
+// The token is cached and refreshed shortly before it expires.
+type OAuth2Authenticator struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret *SecretFile
+	Scopes       []string
+	HttpClient   HttpClient
+	// Clock used to expire tokens, SystemClock if nil
+	Clock Clock
+
+	mux       sync.Mutex
+	token     string
+	refreshAt time.Time
+}
+
+func (o *OAuth2Authenticator) Authenticate(req *http.Request) error {
+	token, err := o.accessToken(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (o *OAuth2Authenticator) Invalidate() {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.token = ""
+	o.ClientSecret.Invalidate()
+}
+
+// accessToken returns the cached token or fetches a new one, concurrent callers wait for the same fetch.
+func (o *OAuth2Authenticator) accessToken(req *http.Request) (string, error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	now := o.clock().Now()
+	if o.token != "" && (o.refreshAt.IsZero() || now.Before(o.refreshAt)) {
+		return o.token, nil
+	}
+	secret, err := o.ClientSecret.Read()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	tokenReq, err := http.NewRequest(http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrapf(err, "create token request for %s failed", o.TokenURL)
+	}
+	tokenReq = tokenReq.WithContext(req.Context())
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	tokenReq.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(secret))
+	resp, err := o.HttpClient.Do(tokenReq)
+	if err != nil {
+		return "", errors.Wrapf(err, "request token from %s failed", o.TokenURL)
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", errors.Wrapf(err, "read token response of %s failed", o.TokenURL)
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", errors.Errorf("request token from %s failed with status %d: %s", o.TokenURL, resp.StatusCode, bytes.TrimSpace(content))
+	}
+	var data struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return "", errors.Wrapf(err, "parse token response of %s failed", o.TokenURL)
+	}
+	if data.AccessToken == "" {
+		return "", errors.Errorf("token response of %s contains no access_token", o.TokenURL)
+	}
+	if data.TokenType != "" && !strings.EqualFold(data.TokenType, "bearer") {
+		return "", errors.Errorf("token type %s of %s not supported", data.TokenType, o.TokenURL)
+	}
+	o.token = data.AccessToken
+	o.refreshAt = time.Time{}
+	if data.ExpiresIn > 0 {
+		lifetime := time.Duration(data.ExpiresIn) * time.Second
+		refreshBefore := lifetime / 10
+		if refreshBefore > maxOAuth2RefreshBefore {
+			refreshBefore = maxOAuth2RefreshBefore
+		}
+		o.refreshAt = now.Add(lifetime - refreshBefore)
+	}
+	glog.V(2).Infof("fetched token from %s valid for %ds", o.TokenURL, data.ExpiresIn)
+	return o.token, nil
+}
+
+func (o *OAuth2Authenticator) clock() Clock {
+	if o.Clock != nil {
+		return o.Clock
+	}
+	return SystemClock{}
+}
+
+// AuthHttpClient authenticates all requests. A request rejected with 401 is sent once more with fresh credentials.
+type AuthHttpClient struct {
+	HttpClient    HttpClient
+	Authenticator Authenticator
+}
+
+func (a *AuthHttpClient) Do(req *http.Request) (*http.Response, error) {
+	if err := a.Authenticator.Authenticate(req); err != nil {
+		return nil, errors.Wrap(err, "authenticate request failed")
+	}
+	resp, err := a.HttpClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the body is consumed and can not be sent again
+		return resp, nil
+	}
+	glog.V(1).Infof("%s rejected credentials => retry with fresh credentials", req.URL.Host)
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	a.Authenticator.Invalidate()
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "reset request body failed")
+		}
+		retry.Body = body
+	}
+	if err := a.Authenticator.Authenticate(retry); err != nil {
+		return nil, errors.Wrap(err, "authenticate request failed")
+	}
+	return a.HttpClient.Do(retry)
+}
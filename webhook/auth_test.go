@@ -0,0 +1,220 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authenticator", func() {
+	var dir string
+	var req *http.Request
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(BeNil())
+		return path
+	}
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "auth")
+		Expect(err).To(BeNil())
+		req, err = http.NewRequest(http.MethodPost, "http://www.example.com", nil)
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+	It("validates", func() {
+		Expect((&webhook.AuthConfig{}).Validate()).To(BeEmpty())
+		Expect((&webhook.AuthConfig{Type: "digest"}).Validate()).To(HaveLen(1))
+		Expect((&webhook.AuthConfig{Type: webhook.AuthBasic}).Validate()).To(HaveLen(2))
+		Expect((&webhook.AuthConfig{Type: webhook.AuthOAuth2, TokenURL: "token"}).Validate()).To(HaveLen(3))
+	})
+	It("returns nil without type", func() {
+		authenticator, err := webhook.NewAuthenticator(&webhook.AuthConfig{}, nil)
+		Expect(err).To(BeNil())
+		Expect(authenticator).To(BeNil())
+	})
+	It("sends the bearer token of the file", func() {
+		authenticator, err := webhook.NewAuthenticator(&webhook.AuthConfig{
+			Type:      webhook.AuthBearer,
+			TokenFile: writeFile("token", "s3cr3t\n"),
+		}, nil)
+		Expect(err).To(BeNil())
+		Expect(authenticator.Authenticate(req)).To(BeNil())
+		Expect(req.Header.Get("Authorization")).To(Equal("Bearer s3cr3t"))
+	})
+	It("reads the file again after invalidate", func() {
+		token := &webhook.SecretFile{Path: writeFile("token", "old")}
+		authenticator := &webhook.BearerAuthenticator{Token: token}
+		Expect(authenticator.Authenticate(req)).To(BeNil())
+		writeFile("token", "new")
+		authenticator.Invalidate()
+		Expect(authenticator.Authenticate(req)).To(BeNil())
+		Expect(req.Header.Get("Authorization")).To(Equal("Bearer new"))
+	})
+	It("sends basic auth", func() {
+		authenticator, err := webhook.NewAuthenticator(&webhook.AuthConfig{
+			Type:         webhook.AuthBasic,
+			User:         "webhook",
+			PasswordFile: writeFile("password", "s3cr3t"),
+		}, nil)
+		Expect(err).To(BeNil())
+		Expect(authenticator.Authenticate(req)).To(BeNil())
+		user, password, ok := req.BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(user).To(Equal("webhook"))
+		Expect(password).To(Equal("s3cr3t"))
+	})
+	It("sends the headers of the file", func() {
+		authenticator, err := webhook.NewAuthenticator(&webhook.AuthConfig{
+			Type:       webhook.AuthHeader,
+			HeaderFile: writeFile("headers", "# api access\nX-Api-Key: abc\n\nX-Tenant: 42\n"),
+		}, nil)
+		Expect(err).To(BeNil())
+		Expect(authenticator.Authenticate(req)).To(BeNil())
+		Expect(req.Header.Get("X-Api-Key")).To(Equal("abc"))
+		Expect(req.Header.Get("X-Tenant")).To(Equal("42"))
+	})
+	It("returns error for invalid header lines", func() {
+		authenticator := &webhook.HeaderAuthenticator{
+			Headers: &webhook.SecretFile{Path: writeFile("headers", "X-Api-Key abc")},
+		}
+		Expect(authenticator.Authenticate(req)).NotTo(BeNil())
+	})
+	Context("oauth2", func() {
+		var server *httptest.Server
+		var mux sync.Mutex
+		var tokenRequests []*http.Request
+		var now time.Time
+		var authenticator *webhook.OAuth2Authenticator
+		BeforeEach(func() {
+			tokenRequests = nil
+			server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				if err := req.ParseForm(); err != nil {
+					resp.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				mux.Lock()
+				tokenRequests = append(tokenRequests, req)
+				count := len(tokenRequests)
+				mux.Unlock()
+				if user, password, _ := req.BasicAuth(); user != "client" || password != "s3cr3t" {
+					resp.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				resp.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(resp, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, count)
+			}))
+			now = time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+			clock := &mocks.Clock{}
+			clock.NowStub = func() time.Time {
+				return now
+			}
+			authenticator = &webhook.OAuth2Authenticator{
+				TokenURL:     server.URL,
+				ClientID:     "client",
+				ClientSecret: &webhook.SecretFile{Path: writeFile("secret", "s3cr3t")},
+				Scopes:       []string{"events:write", "events:read"},
+				HttpClient:   http.DefaultClient,
+				Clock:        clock,
+			}
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+		It("fetches a token with client credentials", func() {
+			Expect(authenticator.Authenticate(req)).To(BeNil())
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-1"))
+			Expect(tokenRequests).To(HaveLen(1))
+			Expect(tokenRequests[0].PostForm.Get("grant_type")).To(Equal("client_credentials"))
+			Expect(tokenRequests[0].PostForm.Get("scope")).To(Equal("events:write events:read"))
+		})
+		It("caches the token until shortly before expiry", func() {
+			Expect(authenticator.Authenticate(req)).To(BeNil())
+			now = now.Add(58 * time.Minute)
+			Expect(authenticator.Authenticate(req)).To(BeNil())
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-1"))
+			now = now.Add(time.Minute)
+			Expect(authenticator.Authenticate(req)).To(BeNil())
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-2"))
+		})
+		It("fetches a new token after invalidate", func() {
+			Expect(authenticator.Authenticate(req)).To(BeNil())
+			authenticator.Invalidate()
+			Expect(authenticator.Authenticate(req)).To(BeNil())
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token-2"))
+		})
+		It("returns error if the token endpoint rejects the client", func() {
+			writeFile("secret", "wrong")
+			authenticator.ClientSecret.Invalidate()
+			Expect(authenticator.Authenticate(req)).NotTo(BeNil())
+		})
+	})
+})
+
+var _ = Describe("AuthHttpClient", func() {
+	var httpClient *mocks.HttpClient
+	var authenticator *mocks.Authenticator
+	var client *webhook.AuthHttpClient
+	var bodies []string
+	BeforeEach(func() {
+		bodies = nil
+		httpClient = &mocks.HttpClient{}
+		httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+			content, err := ioutil.ReadAll(req.Body)
+			Expect(err).To(BeNil())
+			bodies = append(bodies, string(content))
+			if req.Header.Get("Authorization") != "Bearer fresh" {
+				return &http.Response{StatusCode: http.StatusUnauthorized, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}
+		authenticator = &mocks.Authenticator{}
+		authenticator.AuthenticateStub = func(req *http.Request) error {
+			if authenticator.InvalidateCallCount() == 0 {
+				req.Header.Set("Authorization", "Bearer stale")
+			} else {
+				req.Header.Set("Authorization", "Bearer fresh")
+			}
+			return nil
+		}
+		client = &webhook.AuthHttpClient{
+			HttpClient:    httpClient,
+			Authenticator: authenticator,
+		}
+	})
+	It("retries once with fresh credentials on 401", func() {
+		req, err := http.NewRequest(http.MethodPost, "http://www.example.com", bytes.NewBufferString("hello"))
+		Expect(err).To(BeNil())
+		resp, err := client.Do(req)
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(authenticator.InvalidateCallCount()).To(Equal(1))
+		Expect(bodies).To(Equal([]string{"hello", "hello"}))
+	})
+	It("returns the second 401", func() {
+		authenticator.AuthenticateStub = nil
+		req, err := http.NewRequest(http.MethodPost, "http://www.example.com", bytes.NewBufferString("hello"))
+		Expect(err).To(BeNil())
+		resp, err := client.Do(req)
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(httpClient.DoCallCount()).To(Equal(2))
+	})
+})
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the available backoff strategies.
+const (
+	BackoffConstant    = "constant"
+	BackoffLinear      = "linear"
+	BackoffExponential = "exponential"
+	BackoffJitter      = "jitter"
+)
+
+//go:generate counterfeiter -o ../mocks/backoff.go --fake-name Backoff . Backoff
+type Backoff interface {
+	// NextDelay returns the time to wait after the given failed attempt. Previous is the delay returned for the attempt before.
+	NextDelay(attempt int, previous time.Duration) time.Duration
+}
+
+// NewBackoff returns the backoff strategy with the given name, linear if empty. The delay is capped by maxDelay if greater zero.
+func NewBackoff(name string, delay time.Duration, maxDelay time.Duration) (Backoff, error) {
+	var backoff Backoff
+	switch name {
+	case BackoffConstant:
+		backoff = &ConstantBackoff{Delay: delay}
+	case "", BackoffLinear:
+		backoff = &LinearBackoff{Delay: delay}
+	case BackoffExponential:
+		backoff = &ExponentialBackoff{Delay: delay}
+	case BackoffJitter:
+		backoff = &DecorrelatedJitterBackoff{Delay: delay}
+	default:
+		return nil, errors.Errorf("unknown backoff %s", name)
+	}
+	if maxDelay > 0 {
+		backoff = &MaxDelayBackoff{Backoff: backoff, MaxDelay: maxDelay}
+	}
+	return backoff, nil
+}
+
+// ConstantBackoff waits the same time after every attempt.
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+func (c *ConstantBackoff) NextDelay(attempt int, previous time.Duration) time.Duration {
+	return c.Delay
+}
+
+// LinearBackoff waits Delay * attempt.
+type LinearBackoff struct {
+	Delay time.Duration
+}
+
+func (l *LinearBackoff) NextDelay(attempt int, previous time.Duration) time.Duration {
+	return l.Delay * time.Duration(attempt)
+}
+
+// ExponentialBackoff waits Delay * 2^(attempt-1).
+type ExponentialBackoff struct {
+	Delay time.Duration
+}
+
+func (e *ExponentialBackoff) NextDelay(attempt int, previous time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return multiplyDuration(e.Delay, math.Pow(2, float64(attempt-1)))
+}
+
+// DecorrelatedJitterBackoff waits a random time between Delay and three times the previous delay.
+type DecorrelatedJitterBackoff struct {
+	Delay time.Duration
+	// Random returns a number in [0.0,1.0), rand.Float64 is used if nil
+	Random func() float64
+}
+
+func (d *DecorrelatedJitterBackoff) NextDelay(attempt int, previous time.Duration) time.Duration {
+	upper := multiplyDuration(previous, 3)
+	if upper <= d.Delay {
+		return d.Delay
+	}
+	random := rand.Float64
+	if d.Random != nil {
+		random = d.Random
+	}
+	return d.Delay + multiplyDuration(upper-d.Delay, random())
+}
+
+// MaxDelayBackoff caps the delay of the given Backoff.
+type MaxDelayBackoff struct {
+	Backoff  Backoff
+	MaxDelay time.Duration
+}
+
+func (m *MaxDelayBackoff) NextDelay(attempt int, previous time.Duration) time.Duration {
+	delay := m.Backoff.NextDelay(attempt, previous)
+	if delay > m.MaxDelay {
+		return m.MaxDelay
+	}
+	return delay
+}
+
+// multiplyDuration multiplies without overflow.
+func multiplyDuration(duration time.Duration, factor float64) time.Duration {
+	result := float64(duration) * factor
+	if result >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(result)
+}
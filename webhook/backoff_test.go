@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"math"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff", func() {
+	It("waits constant", func() {
+		backoff := &webhook.ConstantBackoff{Delay: time.Second}
+		Expect(backoff.NextDelay(1, 0)).To(Equal(time.Second))
+		Expect(backoff.NextDelay(5, time.Second)).To(Equal(time.Second))
+	})
+	It("waits linear", func() {
+		backoff := &webhook.LinearBackoff{Delay: time.Second}
+		Expect(backoff.NextDelay(1, 0)).To(Equal(time.Second))
+		Expect(backoff.NextDelay(5, 4*time.Second)).To(Equal(5 * time.Second))
+	})
+	It("waits exponential", func() {
+		backoff := &webhook.ExponentialBackoff{Delay: time.Second}
+		Expect(backoff.NextDelay(1, 0)).To(Equal(time.Second))
+		Expect(backoff.NextDelay(2, time.Second)).To(Equal(2 * time.Second))
+		Expect(backoff.NextDelay(5, 8*time.Second)).To(Equal(16 * time.Second))
+	})
+	It("does not overflow", func() {
+		backoff := &webhook.ExponentialBackoff{Delay: time.Second}
+		Expect(backoff.NextDelay(1000, 0)).To(Equal(time.Duration(math.MaxInt64)))
+	})
+	It("waits decorrelated jitter", func() {
+		random := 0.5
+		backoff := &webhook.DecorrelatedJitterBackoff{
+			Delay: time.Second,
+			Random: func() float64 {
+				return random
+			},
+		}
+		Expect(backoff.NextDelay(1, 0)).To(Equal(time.Second))
+		Expect(backoff.NextDelay(2, time.Second)).To(Equal(2 * time.Second))
+		Expect(backoff.NextDelay(3, 2*time.Second)).To(Equal(3500 * time.Millisecond))
+		random = 0
+		Expect(backoff.NextDelay(4, 10*time.Second)).To(Equal(time.Second))
+	})
+	It("caps delay", func() {
+		backoff := &webhook.MaxDelayBackoff{
+			Backoff:  &webhook.ExponentialBackoff{Delay: time.Second},
+			MaxDelay: time.Minute,
+		}
+		Expect(backoff.NextDelay(3, 0)).To(Equal(4 * time.Second))
+		Expect(backoff.NextDelay(10, 0)).To(Equal(time.Minute))
+	})
+	It("creates backoff by name", func() {
+		backoff, err := webhook.NewBackoff(webhook.BackoffExponential, time.Second, time.Minute)
+		Expect(err).To(BeNil())
+		Expect(backoff.NextDelay(10, 0)).To(Equal(time.Minute))
+		backoff, err = webhook.NewBackoff("", time.Second, 0)
+		Expect(err).To(BeNil())
+		Expect(backoff).To(Equal(&webhook.LinearBackoff{Delay: time.Second}))
+	})
+	It("returns error for unknown backoff", func() {
+		_, err := webhook.NewBackoff("fibonacci", time.Second, 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,233 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Body formats of batches
+const (
+	// BatchFormatJSON sends a JSON array of records
+	BatchFormatJSON = "json"
+	// BatchFormatNDJSON sends one JSON record per line
+	BatchFormatNDJSON = "ndjson"
+)
+
+// Content types of batches
+const (
+	BatchJSONContentType   = "application/json"
+	BatchNDJSONContentType = "application/x-ndjson"
+)
+
+// BatchCountField is the header with the amount of records in a batch.
+const BatchCountField = "X-Message-Count"
+
+// BatchCoding sends multiple records of a partition with one request. The whole body is signed.
+//
+//	[{"topic":"orders","partition":0,"offset":42,"key":"a2V5","value":"dmFsdWU=","timestamp":"2018-10-01T12:00:00Z","headers":[{"key":"trace","value":"YWJj"}]}]
+//
+// Keys, values and header values are base64 encoded.
+type BatchCoding struct {
+	Url    string
+	Method string
+	// Format of the body, BatchFormatJSON if empty
+	Format string
+	Signer RequestSigner
+	// HeaderFilter selects the record headers to send, all if nil
+	HeaderFilter *HeaderFilter
+	// SignatureVersion selects the signature scheme, SignatureV1 if zero
+	SignatureVersion int
+	// Tolerance is the maximum age of a request with SignatureV2, DefaultTolerance if zero
+	Tolerance time.Duration
+	// Clock used for signature timestamps, SystemClock if nil
+	Clock Clock
+}
+
+// IsBatchFormat returns true if the given name is a known batch format. An empty name selects BatchFormatJSON.
+func IsBatchFormat(name string) bool {
+	return name == "" || name == BatchFormatJSON || name == BatchFormatNDJSON
+}
+
+func (b *BatchCoding) EncodeBatch(msgs []*sarama.ConsumerMessage) (*http.Request, error) {
+	records := make([]batchRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		records = append(records, newBatchRecord(msg, b.HeaderFilter))
+	}
+	content, contentType, err := b.marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(b.Method, b.Url, bytes.NewBuffer(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(BatchCountField, strconv.Itoa(len(msgs)))
+	if err := b.signature().sign(req.Header, batchDeliveryID(msgs), content); err != nil {
+		return nil, errors.Wrap(err, "sign request failed")
+	}
+	return req, nil
+}
+
+// DecodeBatch verifies the request and returns the records of the batch. The format is detected by the Content-Type.
+func (b *BatchCoding) DecodeBatch(req *http.Request) ([]*sarama.ConsumerMessage, error) {
+	content, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body failed")
+	}
+	defer req.Body.Close()
+	if err := b.signature().verify(req.Header, content); err != nil {
+		return nil, &SignatureError{Err: err}
+	}
+	records, err := unmarshalBatch(content, req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*sarama.ConsumerMessage, 0, len(records))
+	for _, record := range records {
+		msg, err := record.message()
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (b *BatchCoding) marshal(records []batchRecord) ([]byte, string, error) {
+	if b.Format != BatchFormatNDJSON {
+		content, err := json.Marshal(records)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "marshal batch failed")
+		}
+		return content, BatchJSONContentType, nil
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, "", errors.Wrap(err, "marshal batch failed")
+		}
+	}
+	return buf.Bytes(), BatchNDJSONContentType, nil
+}
+
+func (b *BatchCoding) signature() signature {
+	return signature{
+		Signer:    b.Signer,
+		Version:   b.SignatureVersion,
+		Tolerance: b.Tolerance,
+		Clock:     b.Clock,
+	}
+}
+
+func unmarshalBatch(content []byte, contentType string) ([]batchRecord, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var records []batchRecord
+	if mediaType != BatchNDJSONContentType {
+		if err := json.Unmarshal(content, &records); err != nil {
+			return nil, errors.Wrap(err, "unmarshal batch failed")
+		}
+		return records, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record batchRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal record %d of batch failed", len(records))
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read batch failed")
+	}
+	return records, nil
+}
+
+type batchRecord struct {
+	Topic          string        `json:"topic"`
+	Partition      int32         `json:"partition"`
+	Offset         int64         `json:"offset"`
+	Key            []byte        `json:"key,omitempty"`
+	Value          []byte        `json:"value"`
+	Timestamp      string        `json:"timestamp,omitempty"`
+	BlockTimestamp string        `json:"block_timestamp,omitempty"`
+	Headers        []batchHeader `json:"headers,omitempty"`
+}
+
+type batchHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+func newBatchRecord(msg *sarama.ConsumerMessage, filter *HeaderFilter) batchRecord {
+	record := batchRecord{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+	}
+	if !msg.Timestamp.IsZero() {
+		record.Timestamp = msg.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if !msg.BlockTimestamp.IsZero() {
+		record.BlockTimestamp = msg.BlockTimestamp.UTC().Format(time.RFC3339Nano)
+	}
+	for _, header := range msg.Headers {
+		if header == nil || !filter.Match(string(header.Key)) {
+			continue
+		}
+		record.Headers = append(record.Headers, batchHeader{
+			Key:   string(header.Key),
+			Value: header.Value,
+		})
+	}
+	return record
+}
+
+func (b *batchRecord) message() (*sarama.ConsumerMessage, error) {
+	msg := &sarama.ConsumerMessage{
+		Topic:     b.Topic,
+		Partition: b.Partition,
+		Offset:    b.Offset,
+		Key:       b.Key,
+		Value:     b.Value,
+	}
+	var err error
+	if b.Timestamp != "" {
+		if msg.Timestamp, err = time.Parse(time.RFC3339Nano, b.Timestamp); err != nil {
+			return nil, errors.Wrap(err, "decode timestamp failed")
+		}
+	}
+	if b.BlockTimestamp != "" {
+		if msg.BlockTimestamp, err = time.Parse(time.RFC3339Nano, b.BlockTimestamp); err != nil {
+			return nil, errors.Wrap(err, "decode block timestamp failed")
+		}
+	}
+	for _, header := range b.Headers {
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{
+			Key:   []byte(header.Key),
+			Value: header.Value,
+		})
+	}
+	return msg, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BatchCoding", func() {
+	var coding *webhook.BatchCoding
+	var msgs []*sarama.ConsumerMessage
+	BeforeEach(func() {
+		coding = &webhook.BatchCoding{
+			Url:    "http://example.com/hook",
+			Method: http.MethodPost,
+			Signer: &webhook.Signer{Secret: "secret"},
+		}
+		msgs = []*sarama.ConsumerMessage{
+			{
+				Topic:     "orders",
+				Partition: 1,
+				Offset:    7,
+				Key:       []byte("key"),
+				Value:     []byte("value"),
+				Timestamp: time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC),
+				Headers:   []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+			},
+			{
+				Topic:     "orders",
+				Partition: 1,
+				Offset:    8,
+				Value:     []byte("other"),
+			},
+		}
+	})
+	It("sends a json array of records", func() {
+		req, err := coding.EncodeBatch(msgs)
+		Expect(err).To(BeNil())
+		Expect(req.Header.Get("Content-Type")).To(Equal(webhook.BatchJSONContentType))
+		Expect(req.Header.Get(webhook.BatchCountField)).To(Equal("2"))
+		Expect(req.Header.Get(webhook.SignaturField)).NotTo(BeEmpty())
+		var records []map[string]interface{}
+		Expect(json.NewDecoder(req.Body).Decode(&records)).To(BeNil())
+		Expect(records).To(HaveLen(2))
+		Expect(records[0]["offset"]).To(Equal(float64(7)))
+		Expect(records[0]["key"]).To(Equal("a2V5"))
+		Expect(records[0]["value"]).To(Equal("dmFsdWU="))
+		Expect(records[0]["timestamp"]).To(Equal("2018-10-01T12:00:00Z"))
+	})
+	It("sends one record per line as ndjson", func() {
+		coding.Format = webhook.BatchFormatNDJSON
+		req, err := coding.EncodeBatch(msgs)
+		Expect(err).To(BeNil())
+		Expect(req.Header.Get("Content-Type")).To(Equal(webhook.BatchNDJSONContentType))
+		body, err := ioutil.ReadAll(req.Body)
+		Expect(err).To(BeNil())
+		Expect(strings.Split(strings.TrimSpace(string(body)), "\n")).To(HaveLen(2))
+	})
+	It("encodes batch to request and back", func() {
+		for _, format := range []string{webhook.BatchFormatJSON, webhook.BatchFormatNDJSON} {
+			coding.Format = format
+			req, err := coding.EncodeBatch(msgs)
+			Expect(err).To(BeNil())
+			decoded, err := coding.DecodeBatch(req)
+			Expect(err).To(BeNil())
+			Expect(decoded).To(Equal(msgs))
+		}
+	})
+	It("sends only selected headers", func() {
+		coding.HeaderFilter = webhook.NewHeaderFilter("", "trace")
+		req, err := coding.EncodeBatch(msgs)
+		Expect(err).To(BeNil())
+		decoded, err := coding.DecodeBatch(req)
+		Expect(err).To(BeNil())
+		Expect(decoded[0].Headers).To(BeEmpty())
+	})
+	It("uses the offsets of the batch as delivery id", func() {
+		coding.SignatureVersion = webhook.SignatureV2
+		req, err := coding.EncodeBatch(msgs)
+		Expect(err).To(BeNil())
+		Expect(req.Header.Get(webhook.DeliveryIdField)).To(Equal("orders-1-7-8"))
+		_, err = coding.DecodeBatch(req)
+		Expect(err).To(BeNil())
+	})
+	It("returns error if signature is invalid", func() {
+		req, err := coding.EncodeBatch(msgs)
+		Expect(err).To(BeNil())
+		coding.Signer = &webhook.Signer{Secret: "other"}
+		_, err = coding.DecodeBatch(req)
+		Expect(err).To(BeAssignableToTypeOf(&webhook.SignatureError{}))
+	})
+})
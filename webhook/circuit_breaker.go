@@ -0,0 +1,233 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultCircuitFailureThreshold is the amount of consecutive failures opening the circuit of a host.
+	DefaultCircuitFailureThreshold = 5
+	// DefaultCircuitCoolDown is the time a circuit stays open before a probe request is sent.
+	DefaultCircuitCoolDown = 30 * time.Second
+	// maxCircuitProbeWait is the longest time requests wait for the probe of a half-open circuit.
+	maxCircuitProbeWait = time.Second
+)
+
+var circuitStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "circuit_state",
+	Help:      "circuit state of a destination host, 0 closed, 1 half-open, 2 open",
+}, []string{"host"})
+
+func init() {
+	prometheus.MustRegister(
+		circuitStateGauge,
+	)
+}
+
+// CircuitState of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed sends all requests.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen sends a single probe request deciding whether the circuit closes or opens again.
+	CircuitHalfOpen
+	// CircuitOpen rejects all requests until the cool-down passed.
+	CircuitOpen
+)
+
+func (c CircuitState) String() string {
+	switch c {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops requests to a host after FailureThreshold consecutive failures for CoolDown.
+type CircuitBreaker struct {
+	Host             string
+	FailureThreshold int
+	CoolDown         time.Duration
+	// Clock used to measure the cool-down, SystemClock if nil
+	Clock Clock
+
+	mux      sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// Allow returns nil if a request may be sent and a CircuitOpenError otherwise.
+// Every allowed request has to be reported with Done or Abort.
+func (c *CircuitBreaker) Allow() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	switch c.state {
+	case CircuitOpen:
+		if remaining := c.openedAt.Add(c.CoolDown).Sub(c.clock().Now()); remaining > 0 {
+			return &CircuitOpenError{Host: c.Host, RetryAfter: remaining}
+		}
+		glog.V(1).Infof("circuit of host %s half-open => send probe", c.Host)
+		c.setState(CircuitHalfOpen)
+		c.probing = true
+	case CircuitHalfOpen:
+		if c.probing {
+			return &CircuitOpenError{Host: c.Host, RetryAfter: c.probeWait()}
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+// Done reports the result of an allowed request.
+func (c *CircuitBreaker) Done(success bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if success {
+		if c.state != CircuitClosed {
+			glog.V(0).Infof("circuit of host %s closed", c.Host)
+		}
+		c.failures = 0
+		c.probing = false
+		c.setState(CircuitClosed)
+		return
+	}
+	switch c.state {
+	case CircuitClosed:
+		c.failures++
+		if c.failures >= c.FailureThreshold {
+			glog.Warningf("circuit of host %s open after %d failures", c.Host, c.failures)
+			c.open()
+		}
+	case CircuitHalfOpen:
+		glog.Warningf("probe of host %s failed => circuit open", c.Host)
+		c.open()
+	}
+}
+
+// Abort releases an allowed request without result, e.g. canceled on shutdown.
+func (c *CircuitBreaker) Abort() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.probing = false
+}
+
+// State returns the current state, an open circuit past its cool-down is reported open until the probe is sent.
+func (c *CircuitBreaker) State() CircuitState {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.state
+}
+
+func (c *CircuitBreaker) open() {
+	c.failures = 0
+	c.probing = false
+	c.openedAt = c.clock().Now()
+	c.setState(CircuitOpen)
+}
+
+func (c *CircuitBreaker) setState(state CircuitState) {
+	c.state = state
+	circuitStateGauge.WithLabelValues(c.Host).Set(float64(state))
+}
+
+func (c *CircuitBreaker) probeWait() time.Duration {
+	if c.CoolDown > 0 && c.CoolDown < maxCircuitProbeWait {
+		return c.CoolDown
+	}
+	return maxCircuitProbeWait
+}
+
+func (c *CircuitBreaker) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return SystemClock{}
+}
+
+// CircuitBreakers holds one CircuitBreaker per host, shared by all routes sending to the host.
+type CircuitBreakers struct {
+	FailureThreshold int
+	CoolDown         time.Duration
+	Clock            Clock
+
+	mux      sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// Get returns the CircuitBreaker of the host and creates it if needed.
+func (c *CircuitBreakers) Get(host string) *CircuitBreaker {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.breakers == nil {
+		c.breakers = make(map[string]*CircuitBreaker)
+	}
+	breaker, ok := c.breakers[host]
+	if !ok {
+		breaker = &CircuitBreaker{
+			Host:             host,
+			FailureThreshold: c.FailureThreshold,
+			CoolDown:         c.CoolDown,
+			Clock:            c.Clock,
+		}
+		breaker.setState(CircuitClosed)
+		c.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// Tripped returns the sorted hosts of all circuits not closed.
+func (c *CircuitBreakers) Tripped() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var hosts []string
+	for host, breaker := range c.breakers {
+		if breaker.State() != CircuitClosed {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// CircuitBreakerHttpClient sends requests only while the circuit of their host allows it.
+// Transport errors and 5xx responses count as failures.
+type CircuitBreakerHttpClient struct {
+	HttpClient HttpClient
+	Breakers   *CircuitBreakers
+}
+
+func (c *CircuitBreakerHttpClient) Do(req *http.Request) (*http.Response, error) {
+	breaker := c.Breakers.Get(req.URL.Host)
+	if err := breaker.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := c.HttpClient.Do(req)
+	switch {
+	case err != nil && req.Context().Err() == context.Canceled:
+		breaker.Abort()
+	case err != nil:
+		breaker.Done(false)
+	default:
+		breaker.Done(resp.StatusCode < 500)
+	}
+	return resp, err
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var now time.Time
+	var clock *mocks.Clock
+	var breakers *webhook.CircuitBreakers
+	var httpClient *mocks.HttpClient
+	var client *webhook.CircuitBreakerHttpClient
+	var status int
+	send := func(url string) error {
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		Expect(err).To(BeNil())
+		_, err = client.Do(req)
+		return err
+	}
+	BeforeEach(func() {
+		now = time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+		clock = &mocks.Clock{}
+		clock.NowStub = func() time.Time {
+			return now
+		}
+		breakers = &webhook.CircuitBreakers{
+			FailureThreshold: 3,
+			CoolDown:         30 * time.Second,
+			Clock:            clock,
+		}
+		status = http.StatusInternalServerError
+		httpClient = &mocks.HttpClient{}
+		httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status}, nil
+		}
+		client = &webhook.CircuitBreakerHttpClient{
+			HttpClient: httpClient,
+			Breakers:   breakers,
+		}
+	})
+	It("opens after consecutive failures", func() {
+		for i := 0; i < 3; i++ {
+			Expect(send("http://a.example.com/hook")).To(BeNil())
+		}
+		Expect(breakers.Get("a.example.com").State()).To(Equal(webhook.CircuitOpen))
+		err := send("http://a.example.com/hook")
+		Expect(err).To(Equal(&webhook.CircuitOpenError{Host: "a.example.com", RetryAfter: 30 * time.Second}))
+		Expect(httpClient.DoCallCount()).To(Equal(3))
+		Expect(breakers.Tripped()).To(Equal([]string{"a.example.com"}))
+	})
+	It("keeps the circuits of other hosts closed", func() {
+		for i := 0; i < 3; i++ {
+			Expect(send("http://a.example.com/hook")).To(BeNil())
+		}
+		status = http.StatusOK
+		Expect(send("http://b.example.com/hook")).To(BeNil())
+		Expect(breakers.Get("b.example.com").State()).To(Equal(webhook.CircuitClosed))
+	})
+	It("resets failures on success", func() {
+		for i := 0; i < 2; i++ {
+			Expect(send("http://a.example.com/hook")).To(BeNil())
+		}
+		status = http.StatusBadRequest
+		Expect(send("http://a.example.com/hook")).To(BeNil())
+		status = http.StatusInternalServerError
+		Expect(send("http://a.example.com/hook")).To(BeNil())
+		Expect(breakers.Get("a.example.com").State()).To(Equal(webhook.CircuitClosed))
+	})
+	It("counts transport errors as failures", func() {
+		httpClient.DoStub = nil
+		httpClient.DoReturns(nil, errors.New("connection refused"))
+		for i := 0; i < 3; i++ {
+			Expect(send("http://a.example.com/hook")).NotTo(BeNil())
+		}
+		Expect(breakers.Get("a.example.com").State()).To(Equal(webhook.CircuitOpen))
+	})
+	Context("after cool-down", func() {
+		BeforeEach(func() {
+			for i := 0; i < 3; i++ {
+				Expect(send("http://a.example.com/hook")).To(BeNil())
+			}
+			now = now.Add(30 * time.Second)
+		})
+		It("closes if the probe succeeds", func() {
+			status = http.StatusOK
+			Expect(send("http://a.example.com/hook")).To(BeNil())
+			Expect(breakers.Get("a.example.com").State()).To(Equal(webhook.CircuitClosed))
+			Expect(breakers.Tripped()).To(BeEmpty())
+		})
+		It("opens again if the probe fails", func() {
+			Expect(send("http://a.example.com/hook")).To(BeNil())
+			Expect(breakers.Get("a.example.com").State()).To(Equal(webhook.CircuitOpen))
+			Expect(send("http://a.example.com/hook")).To(BeAssignableToTypeOf(&webhook.CircuitOpenError{}))
+			Expect(httpClient.DoCallCount()).To(Equal(4))
+		})
+		It("sends a single probe", func() {
+			breaker := breakers.Get("a.example.com")
+			Expect(breaker.Allow()).To(BeNil())
+			Expect(breaker.State()).To(Equal(webhook.CircuitHalfOpen))
+			Expect(breaker.Allow()).To(Equal(&webhook.CircuitOpenError{Host: "a.example.com", RetryAfter: time.Second}))
+			breaker.Abort()
+			Expect(breaker.Allow()).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,24 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import "time"
+
+//go:generate counterfeiter -o ../mocks/clock.go --fake-name Clock . Clock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock of the operating system.
+type SystemClock struct{}
+
+func (s SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (s SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
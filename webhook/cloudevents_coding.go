@@ -0,0 +1,401 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents spec sent.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsContentType is the content type of CloudEvents in structured mode.
+	CloudEventsContentType = "application/cloudevents+json"
+	// DefaultCloudEventsType is the type of the events if none is configured.
+	DefaultCloudEventsType = "com.github.bborbe.kafka-webhook.record"
+)
+
+// CloudEvents attributes and kafka extensions in HTTP binary mode
+const (
+	CloudEventsSpecVersionField    = "Ce-Specversion"
+	CloudEventsIdField             = "Ce-Id"
+	CloudEventsSourceField         = "Ce-Source"
+	CloudEventsTypeField           = "Ce-Type"
+	CloudEventsTimeField           = "Ce-Time"
+	CloudEventsKafkaTopicField     = "Ce-Kafkatopic"
+	CloudEventsKafkaPartitionField = "Ce-Kafkapartition"
+	CloudEventsKafkaOffsetField    = "Ce-Kafkaoffset"
+	CloudEventsKafkaKeyField       = "Ce-Kafkakey"
+)
+
+// CloudEventsHeaderAttributePrefix is the prefix of the extension attributes record headers are sent as in structured mode.
+const CloudEventsHeaderAttributePrefix = "kafkaheader"
+
+// CloudEventsBinaryCoding sends records as CloudEvents 1.0 in HTTP binary mode.
+// The record value is the body, attributes and the kafka extensions are sent as ce-* headers.
+type CloudEventsBinaryCoding struct {
+	Url    string
+	Method string
+	// Source of the events, "/topics/<topic>" if empty
+	Source string
+	// Type of the events, DefaultCloudEventsType if empty
+	Type string
+	// ContentType of the record values, application/octet-stream if empty
+	ContentType string
+	Signer      RequestSigner
+	// HeaderPrefix is prepended to the keys of record headers sent as HTTP headers, record headers are dropped if empty
+	HeaderPrefix string
+	// HeaderFilter selects the record headers to send, all if nil
+	HeaderFilter *HeaderFilter
+	// SignatureVersion selects the signature scheme, SignatureV1 if zero
+	SignatureVersion int
+	// Tolerance is the maximum age of a request with SignatureV2, DefaultTolerance if zero
+	Tolerance time.Duration
+	// Clock used for signature timestamps, SystemClock if nil
+	Clock Clock
+}
+
+func (c *CloudEventsBinaryCoding) Encode(msg *sarama.ConsumerMessage) (*http.Request, error) {
+	req, err := http.NewRequest(c.Method, c.Url, bytes.NewBuffer(msg.Value))
+	if err != nil {
+		return nil, errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Content-Type", contentTypeOrDefault(c.ContentType))
+	req.Header.Set(CloudEventsSpecVersionField, CloudEventsSpecVersion)
+	req.Header.Set(CloudEventsIdField, DeliveryID(msg))
+	req.Header.Set(CloudEventsSourceField, cloudEventsSource(c.Source, msg.Topic))
+	req.Header.Set(CloudEventsTypeField, cloudEventsType(c.Type))
+	if !msg.Timestamp.IsZero() {
+		req.Header.Set(CloudEventsTimeField, msg.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+	req.Header.Set(CloudEventsKafkaTopicField, msg.Topic)
+	req.Header.Set(CloudEventsKafkaPartitionField, strconv.FormatInt(int64(msg.Partition), 10))
+	req.Header.Set(CloudEventsKafkaOffsetField, strconv.FormatInt(msg.Offset, 10))
+	if len(msg.Key) > 0 {
+		req.Header.Set(CloudEventsKafkaKeyField, base64.StdEncoding.EncodeToString(msg.Key))
+	}
+	if c.HeaderPrefix != "" {
+		encodeRecordHeaders(req.Header, c.HeaderPrefix, c.HeaderFilter, msg.Headers)
+	}
+	if err := c.signature().sign(req.Header, DeliveryID(msg), msg.Value); err != nil {
+		return nil, errors.Wrap(err, "sign request failed")
+	}
+	return req, nil
+}
+
+func (c *CloudEventsBinaryCoding) Decode(req *http.Request) (*sarama.ConsumerMessage, error) {
+	content, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body failed")
+	}
+	defer req.Body.Close()
+	if err := c.signature().verify(req.Header, content); err != nil {
+		return nil, &SignatureError{Err: err}
+	}
+	if version := req.Header.Get(CloudEventsSpecVersionField); version != CloudEventsSpecVersion {
+		return nil, errors.Errorf("cloudevents spec version %s unsupported", version)
+	}
+	event := cloudEvent{
+		Time:           req.Header.Get(CloudEventsTimeField),
+		KafkaTopic:     req.Header.Get(CloudEventsKafkaTopicField),
+		KafkaPartition: req.Header.Get(CloudEventsKafkaPartitionField),
+		KafkaOffset:    req.Header.Get(CloudEventsKafkaOffsetField),
+		KafkaKey:       req.Header.Get(CloudEventsKafkaKeyField),
+	}
+	msg, err := event.message()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyDeliveryID(req.Header, msg); err != nil {
+		return nil, err
+	}
+	msg.Value = content
+	if c.HeaderPrefix != "" {
+		if msg.Headers, err = decodeRecordHeaders(req.Header, c.HeaderPrefix); err != nil {
+			return nil, errors.Wrap(err, "decode headers failed")
+		}
+	}
+	return msg, nil
+}
+
+func (c *CloudEventsBinaryCoding) signature() signature {
+	return signature{
+		Signer:    c.Signer,
+		Version:   c.SignatureVersion,
+		Tolerance: c.Tolerance,
+		Clock:     c.Clock,
+	}
+}
+
+// CloudEventsStructuredCoding sends records as CloudEvents 1.0 in structured JSON mode.
+// Values of a JSON content type are sent unchanged as data, all others base64 encoded as data_base64.
+// Record headers are sent as extension attributes kafkaheader<name>. Attribute names are restricted to
+// lowercase letters and digits, so names are lowercased and other characters are removed.
+type CloudEventsStructuredCoding struct {
+	Url    string
+	Method string
+	// Source of the events, "/topics/<topic>" if empty
+	Source string
+	// Type of the events, DefaultCloudEventsType if empty
+	Type string
+	// ContentType of the record values, application/octet-stream if empty
+	ContentType string
+	Signer      RequestSigner
+	// RecordHeaders sends the record headers as extension attributes
+	RecordHeaders bool
+	// HeaderFilter selects the record headers to send, all if nil
+	HeaderFilter *HeaderFilter
+	// SignatureVersion selects the signature scheme, SignatureV1 if zero
+	SignatureVersion int
+	// Tolerance is the maximum age of a request with SignatureV2, DefaultTolerance if zero
+	Tolerance time.Duration
+	// Clock used for signature timestamps, SystemClock if nil
+	Clock Clock
+}
+
+func (c *CloudEventsStructuredCoding) Encode(msg *sarama.ConsumerMessage) (*http.Request, error) {
+	event := cloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              DeliveryID(msg),
+		Source:          cloudEventsSource(c.Source, msg.Topic),
+		Type:            cloudEventsType(c.Type),
+		DataContentType: contentTypeOrDefault(c.ContentType),
+		KafkaTopic:      msg.Topic,
+		KafkaPartition:  strconv.FormatInt(int64(msg.Partition), 10),
+		KafkaOffset:     strconv.FormatInt(msg.Offset, 10),
+	}
+	if !msg.Timestamp.IsZero() {
+		event.Time = msg.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if len(msg.Key) > 0 {
+		event.KafkaKey = base64.StdEncoding.EncodeToString(msg.Key)
+	}
+	jsonData := isJSONContentType(event.DataContentType) && json.Valid(msg.Value)
+	if !jsonData && len(msg.Value) > 0 {
+		event.DataBase64 = base64.StdEncoding.EncodeToString(msg.Value)
+	}
+	content, err := json.Marshal(event)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal event failed")
+	}
+	// extensions and data are appended to the object, json.Marshal would compact and escape the data
+	buf := bytes.NewBuffer(content[:len(content)-1])
+	if c.RecordHeaders {
+		if err := c.writeHeaderAttributes(buf, msg.Headers); err != nil {
+			return nil, err
+		}
+	}
+	if jsonData {
+		buf.WriteString(`,"data":`)
+		buf.Write(msg.Value)
+	}
+	buf.WriteString("}")
+	content = buf.Bytes()
+	req, err := http.NewRequest(c.Method, c.Url, bytes.NewBuffer(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Content-Type", CloudEventsContentType+"; charset=utf-8")
+	if err := c.signature().sign(req.Header, DeliveryID(msg), content); err != nil {
+		return nil, errors.Wrap(err, "sign request failed")
+	}
+	return req, nil
+}
+
+func (c *CloudEventsStructuredCoding) Decode(req *http.Request) (*sarama.ConsumerMessage, error) {
+	content, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body failed")
+	}
+	defer req.Body.Close()
+	if err := c.signature().verify(req.Header, content); err != nil {
+		return nil, &SignatureError{Err: err}
+	}
+	var event cloudEvent
+	if err := json.Unmarshal(content, &event); err != nil {
+		return nil, errors.Wrap(err, "unmarshal event failed")
+	}
+	if event.SpecVersion != CloudEventsSpecVersion {
+		return nil, errors.Errorf("cloudevents spec version %s unsupported", event.SpecVersion)
+	}
+	msg, err := event.message()
+	if err != nil {
+		return nil, err
+	}
+	if event.DataBase64 != "" {
+		if msg.Value, err = base64.StdEncoding.DecodeString(event.DataBase64); err != nil {
+			return nil, errors.Wrap(err, "decode data failed")
+		}
+	} else if len(event.Data) > 0 {
+		msg.Value = event.Data
+	}
+	if c.RecordHeaders {
+		if msg.Headers, err = decodeHeaderAttributes(content); err != nil {
+			return nil, errors.Wrap(err, "decode headers failed")
+		}
+	}
+	return msg, nil
+}
+
+// writeHeaderAttributes writes the record headers as extension attributes, the first of repeated names wins.
+func (c *CloudEventsStructuredCoding) writeHeaderAttributes(buf *bytes.Buffer, recordHeaders []*sarama.RecordHeader) error {
+	written := make(map[string]bool)
+	for _, recordHeader := range recordHeaders {
+		if recordHeader == nil || !c.HeaderFilter.Match(string(recordHeader.Key)) {
+			continue
+		}
+		name := cloudEventsHeaderAttribute(string(recordHeader.Key))
+		if name == CloudEventsHeaderAttributePrefix || written[name] {
+			glog.V(2).Infof("skip record header %q: no unique extension attribute name", recordHeader.Key)
+			continue
+		}
+		written[name] = true
+		value, err := json.Marshal(encodeHeaderValue(recordHeader.Value))
+		if err != nil {
+			return errors.Wrapf(err, "marshal header %s failed", name)
+		}
+		fmt.Fprintf(buf, `,"%s":%s`, name, value)
+	}
+	return nil
+}
+
+// cloudEventsHeaderAttribute returns the extension attribute name of a record header.
+func cloudEventsHeaderAttribute(key string) string {
+	name := []byte(CloudEventsHeaderAttributePrefix)
+	for _, c := range []byte(strings.ToLower(key)) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			name = append(name, c)
+		}
+	}
+	return string(name)
+}
+
+// decodeHeaderAttributes returns the record headers of all kafkaheader extension attributes of the event, sorted by name.
+func decodeHeaderAttributes(content []byte) ([]*sarama.RecordHeader, error) {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(content, &attributes); err != nil {
+		return nil, errors.Wrap(err, "unmarshal event failed")
+	}
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		if strings.HasPrefix(name, CloudEventsHeaderAttributePrefix) && len(name) > len(CloudEventsHeaderAttributePrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var result []*sarama.RecordHeader
+	for _, name := range names {
+		var value string
+		if err := json.Unmarshal(attributes[name], &value); err != nil {
+			return nil, errors.Wrapf(err, "attribute %s is no string", name)
+		}
+		decoded, err := decodeHeaderValue(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode attribute %s failed", name)
+		}
+		result = append(result, &sarama.RecordHeader{
+			Key:   []byte(name[len(CloudEventsHeaderAttributePrefix):]),
+			Value: decoded,
+		})
+	}
+	return result, nil
+}
+
+func (c *CloudEventsStructuredCoding) signature() signature {
+	return signature{
+		Signer:    c.Signer,
+		Version:   c.SignatureVersion,
+		Tolerance: c.Tolerance,
+		Clock:     c.Clock,
+	}
+}
+
+// cloudEvent is a CloudEvent in structured JSON mode. The kafka extensions are strings as in binary mode.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      string          `json:"data_base64,omitempty"`
+	KafkaTopic      string          `json:"kafkatopic"`
+	KafkaPartition  string          `json:"kafkapartition"`
+	KafkaOffset     string          `json:"kafkaoffset"`
+	KafkaKey        string          `json:"kafkakey,omitempty"`
+}
+
+// message returns a record with key, topic, partition, offset and timestamp of the event.
+func (e *cloudEvent) message() (*sarama.ConsumerMessage, error) {
+	key, err := base64.StdEncoding.DecodeString(e.KafkaKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode key failed")
+	}
+	offset, err := strconv.ParseInt(e.KafkaOffset, 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode offset failed")
+	}
+	partition, err := strconv.ParseInt(e.KafkaPartition, 10, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode partition failed")
+	}
+	msg := &sarama.ConsumerMessage{
+		Key:       key,
+		Topic:     e.KafkaTopic,
+		Offset:    offset,
+		Partition: int32(partition),
+	}
+	if e.Time != "" {
+		if msg.Timestamp, err = time.Parse(time.RFC3339Nano, e.Time); err != nil {
+			return nil, errors.Wrap(err, "decode time failed")
+		}
+	}
+	return msg, nil
+}
+
+func cloudEventsSource(source string, topic string) string {
+	if source == "" {
+		return "/topics/" + topic
+	}
+	return source
+}
+
+func cloudEventsType(eventType string) string {
+	if eventType == "" {
+		return DefaultCloudEventsType
+	}
+	return eventType
+}
+
+func contentTypeOrDefault(contentType string) string {
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// isJSONContentType returns true for application/json and all +json types.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudEventsCoding", func() {
+	var msg *sarama.ConsumerMessage
+	BeforeEach(func() {
+		msg = &sarama.ConsumerMessage{
+			Topic:     "orders",
+			Partition: 2,
+			Offset:    42,
+			Key:       []byte("key"),
+			Value:     []byte(`{"id":1}`),
+			Timestamp: time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC),
+		}
+	})
+	Context("binary", func() {
+		var coding *webhook.CloudEventsBinaryCoding
+		BeforeEach(func() {
+			coding = &webhook.CloudEventsBinaryCoding{
+				Url:          "http://example.com/hook",
+				Method:       http.MethodPost,
+				ContentType:  "application/json",
+				Signer:       &webhook.Signer{Secret: "secret"},
+				HeaderPrefix: webhook.DefaultHeaderPrefix,
+			}
+		})
+		It("sets attributes as ce headers", func() {
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get("ce-specversion")).To(Equal("1.0"))
+			Expect(req.Header.Get("ce-id")).To(Equal("orders-2-42"))
+			Expect(req.Header.Get("ce-source")).To(Equal("/topics/orders"))
+			Expect(req.Header.Get("ce-type")).To(Equal(webhook.DefaultCloudEventsType))
+			Expect(req.Header.Get("ce-time")).To(Equal("2018-10-01T12:00:00Z"))
+			Expect(req.Header.Get("ce-kafkapartition")).To(Equal("2"))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(req.Header.Get(webhook.SignaturField)).NotTo(BeEmpty())
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).To(BeNil())
+			Expect(body).To(Equal(msg.Value))
+		})
+		It("encodes sarama message to request and back", func() {
+			msg.Headers = []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}}
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			message, err := coding.Decode(req)
+			Expect(err).To(BeNil())
+			Expect(message).To(Equal(msg))
+		})
+		It("returns error if signature is invalid", func() {
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			coding.Signer = &webhook.Signer{Secret: "other"}
+			_, err = coding.Decode(req)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("structured", func() {
+		var coding *webhook.CloudEventsStructuredCoding
+		BeforeEach(func() {
+			coding = &webhook.CloudEventsStructuredCoding{
+				Url:         "http://example.com/hook",
+				Method:      http.MethodPost,
+				Source:      "urn:orders",
+				Type:        "order.created",
+				ContentType: "application/json",
+				Signer:      &webhook.Signer{Secret: "secret"},
+			}
+		})
+		It("sends json values as data", func() {
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get("Content-Type")).To(HavePrefix(webhook.CloudEventsContentType))
+			var event map[string]interface{}
+			Expect(json.NewDecoder(req.Body).Decode(&event)).To(BeNil())
+			Expect(event["specversion"]).To(Equal("1.0"))
+			Expect(event["id"]).To(Equal("orders-2-42"))
+			Expect(event["source"]).To(Equal("urn:orders"))
+			Expect(event["type"]).To(Equal("order.created"))
+			Expect(event["data"]).To(Equal(map[string]interface{}{"id": float64(1)}))
+			Expect(event).NotTo(HaveKey("data_base64"))
+		})
+		It("sends other values as data_base64", func() {
+			coding.ContentType = ""
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			var event map[string]interface{}
+			Expect(json.NewDecoder(req.Body).Decode(&event)).To(BeNil())
+			Expect(event["data_base64"]).To(Equal("eyJpZCI6MX0="))
+			Expect(event).NotTo(HaveKey("data"))
+		})
+		It("encodes sarama message to request and back", func() {
+			for _, contentType := range []string{"", "application/json"} {
+				coding.ContentType = contentType
+				req, err := coding.Encode(msg)
+				Expect(err).To(BeNil())
+				message, err := coding.Decode(req)
+				Expect(err).To(BeNil())
+				Expect(message).To(Equal(msg))
+			}
+		})
+		It("keeps json data unchanged", func() {
+			msg.Value = []byte("{\"name\": \"<b>\",\n  \"id\": 1.0}")
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			content, err := ioutil.ReadAll(req.Body)
+			Expect(err).To(BeNil())
+			Expect(string(content)).To(ContainSubstring(`"data":` + string(msg.Value)))
+			req, err = coding.Encode(msg)
+			Expect(err).To(BeNil())
+			message, err := coding.Decode(req)
+			Expect(err).To(BeNil())
+			Expect(message.Value).To(Equal(msg.Value))
+		})
+		It("sends record headers as extension attributes", func() {
+			coding.RecordHeaders = true
+			msg.Headers = []*sarama.RecordHeader{
+				{Key: []byte("Trace-ID"), Value: []byte("abc")},
+				{Key: []byte("binary"), Value: []byte{0xff}},
+			}
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			var event map[string]interface{}
+			Expect(json.NewDecoder(req.Body).Decode(&event)).To(BeNil())
+			Expect(event["kafkaheadertraceid"]).To(Equal("abc"))
+			Expect(event).To(HaveKey("kafkaheaderbinary"))
+			req, err = coding.Encode(msg)
+			Expect(err).To(BeNil())
+			message, err := coding.Decode(req)
+			Expect(err).To(BeNil())
+			Expect(message.Headers).To(Equal([]*sarama.RecordHeader{
+				{Key: []byte("binary"), Value: []byte{0xff}},
+				{Key: []byte("traceid"), Value: []byte("abc")},
+			}))
+		})
+		It("drops record headers if disabled", func() {
+			msg.Headers = []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}}
+			req, err := coding.Encode(msg)
+			Expect(err).To(BeNil())
+			var event map[string]interface{}
+			Expect(json.NewDecoder(req.Body).Decode(&event)).To(BeNil())
+			Expect(event).NotTo(HaveKey("kafkaheadertrace"))
+		})
+	})
+})
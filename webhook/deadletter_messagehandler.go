@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Headers added to records sent to the dead letter topic.
+const (
+	DeadLetterStatusHeader    = "dead-letter-status"
+	DeadLetterErrorHeader     = "dead-letter-error"
+	DeadLetterAttemptsHeader  = "dead-letter-attempts"
+	DeadLetterTopicHeader     = "dead-letter-topic"
+	DeadLetterPartitionHeader = "dead-letter-partition"
+	DeadLetterOffsetHeader    = "dead-letter-offset"
+	DeadLetterTimestampHeader = "dead-letter-timestamp"
+	DeadLetterFailedAtHeader  = "dead-letter-failed-at"
+)
+
+// DeadLetterMessageHandler sends all messages the given MessageHandler failed to handle to the dead letter topic.
+type DeadLetterMessageHandler struct {
+	// MessageHandler to call
+	MessageHandler MessageHandler
+	// BatchHandler to call with batches
+	BatchHandler BatchMessageHandler
+	// Producer used to send failed messages
+	Producer sarama.SyncProducer
+	// Topic failed messages are sent to
+	Topic string
+	// Clock used for the failure time, SystemClock if nil
+	Clock Clock
+}
+
+// ConsumeMessage returns no error if the message was handled or sent to the dead letter topic.
+func (d *DeadLetterMessageHandler) ConsumeMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	err := d.MessageHandler.ConsumeMessage(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	partition, offset, sendErr := d.Producer.SendMessage(d.createMessage(msg, err))
+	if sendErr != nil {
+		return errors.Wrapf(sendErr, "send message %d to dead letter topic %s failed after: %v", msg.Offset, d.Topic, err)
+	}
+	glog.V(1).Infof("message %d of topic %s sent to dead letter topic %s partition %d offset %d: %v", msg.Offset, msg.Topic, d.Topic, partition, offset, err)
+	return nil
+}
+
+// ConsumeMessages returns no error if the batch was handled or all its messages were sent to the dead letter topic.
+func (d *DeadLetterMessageHandler) ConsumeMessages(ctx context.Context, msgs []*sarama.ConsumerMessage) error {
+	err := d.BatchHandler.ConsumeMessages(ctx, msgs)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	deadLetters := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		deadLetters = append(deadLetters, d.createMessage(msg, err))
+	}
+	if sendErr := d.Producer.SendMessages(deadLetters); sendErr != nil {
+		return errors.Wrapf(sendErr, "send batch of %d messages to dead letter topic %s failed after: %v", len(msgs), d.Topic, err)
+	}
+	glog.V(1).Infof("batch of %d messages sent to dead letter topic %s: %v", len(msgs), d.Topic, err)
+	return nil
+}
+
+func (d *DeadLetterMessageHandler) createMessage(msg *sarama.ConsumerMessage, err error) *sarama.ProducerMessage {
+	var headers []sarama.RecordHeader
+	for _, header := range msg.Headers {
+		if header != nil {
+			headers = append(headers, *header)
+		}
+	}
+	addHeader := func(key, value string) {
+		headers = append(headers, sarama.RecordHeader{
+			Key:   []byte(key),
+			Value: []byte(value),
+		})
+	}
+	if statusError := statusErrorOf(err); statusError != nil {
+		addHeader(DeadLetterStatusHeader, strconv.Itoa(statusError.StatusCode))
+	}
+	if attempts, ok := attemptsOf(err); ok {
+		addHeader(DeadLetterAttemptsHeader, strconv.Itoa(attempts))
+	}
+	addHeader(DeadLetterErrorHeader, err.Error())
+	addHeader(DeadLetterTopicHeader, msg.Topic)
+	addHeader(DeadLetterPartitionHeader, strconv.FormatInt(int64(msg.Partition), 10))
+	addHeader(DeadLetterOffsetHeader, strconv.FormatInt(msg.Offset, 10))
+	if !msg.Timestamp.IsZero() {
+		addHeader(DeadLetterTimestampHeader, msg.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+	addHeader(DeadLetterFailedAtHeader, d.clock().Now().UTC().Format(time.RFC3339Nano))
+
+	result := &sarama.ProducerMessage{
+		Topic:   d.Topic,
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		result.Key = sarama.ByteEncoder(msg.Key)
+	}
+	if msg.Value != nil {
+		result.Value = sarama.ByteEncoder(msg.Value)
+	}
+	return result
+}
+
+func (d *DeadLetterMessageHandler) clock() Clock {
+	if d.Clock != nil {
+		return d.Clock
+	}
+	return SystemClock{}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	saramamocks "github.com/Shopify/sarama/mocks"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+type recordingSyncProducer struct {
+	*saramamocks.SyncProducer
+	messages []*sarama.ProducerMessage
+}
+
+func (r *recordingSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	r.messages = append(r.messages, msg)
+	return r.SyncProducer.SendMessage(msg)
+}
+
+func (r *recordingSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	r.messages = append(r.messages, msgs...)
+	return r.SyncProducer.SendMessages(msgs)
+}
+
+var _ = Describe("DeadLetterMessageHandler", func() {
+	var deadLetterMessageHandler *webhook.DeadLetterMessageHandler
+	var messageHandler *mocks.MessageHandler
+	var producer *recordingSyncProducer
+	var msg *sarama.ConsumerMessage
+	failedAt := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	BeforeEach(func() {
+		messageHandler = &mocks.MessageHandler{}
+		clock := &mocks.Clock{}
+		clock.NowReturns(failedAt)
+		producer = &recordingSyncProducer{
+			SyncProducer: saramamocks.NewSyncProducer(GinkgoT(), nil),
+		}
+		deadLetterMessageHandler = &webhook.DeadLetterMessageHandler{
+			MessageHandler: messageHandler,
+			Producer:       producer,
+			Topic:          "dead-letter",
+			Clock:          clock,
+		}
+		msg = &sarama.ConsumerMessage{
+			Topic:     "my-topic",
+			Partition: 7,
+			Offset:    42,
+			Key:       []byte("key"),
+			Value:     []byte("value"),
+			Timestamp: time.Date(2018, 9, 30, 8, 0, 0, 0, time.UTC),
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("trace-id"), Value: []byte("abc")},
+			},
+		}
+	})
+	AfterEach(func() {
+		Expect(producer.Close()).To(BeNil())
+	})
+	It("sends all messages of a failed batch to dead letter topic", func() {
+		batchHandler := &mocks.BatchMessageHandler{}
+		batchHandler.ConsumeMessagesReturns(&webhook.StatusError{StatusCode: 500})
+		deadLetterMessageHandler.BatchHandler = batchHandler
+		producer.ExpectSendMessageAndSucceed()
+		producer.ExpectSendMessageAndSucceed()
+		second := *msg
+		second.Offset = 43
+
+		err := deadLetterMessageHandler.ConsumeMessages(context.Background(), []*sarama.ConsumerMessage{msg, &second})
+		Expect(err).To(BeNil())
+		Expect(producer.messages).To(HaveLen(2))
+		for i, offset := range []string{"42", "43"} {
+			headers := make(map[string]string)
+			for _, header := range producer.messages[i].Headers {
+				headers[string(header.Key)] = string(header.Value)
+			}
+			Expect(headers).To(HaveKeyWithValue(webhook.DeadLetterOffsetHeader, offset))
+			Expect(headers).To(HaveKeyWithValue(webhook.DeadLetterStatusHeader, "500"))
+		}
+	})
+	It("sends nothing if message handler succeeds", func() {
+		err := deadLetterMessageHandler.ConsumeMessage(context.Background(), msg)
+		Expect(err).To(BeNil())
+		Expect(producer.messages).To(BeEmpty())
+	})
+	It("sends failed message to dead letter topic", func() {
+		producer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+			if string(value) != "value" {
+				return errors.Errorf("unexpected value %s", value)
+			}
+			return nil
+		})
+		messageHandler.ConsumeMessageReturns(&webhook.MaxRetriesError{
+			Attempts: 4,
+			Err:      errors.Wrap(&webhook.StatusError{StatusCode: 503}, "perform request failed"),
+		})
+
+		err := deadLetterMessageHandler.ConsumeMessage(context.Background(), msg)
+		Expect(err).To(BeNil())
+		Expect(producer.messages).To(HaveLen(1))
+		message := producer.messages[0]
+		Expect(message.Topic).To(Equal("dead-letter"))
+		Expect(message.Key).To(Equal(sarama.ByteEncoder("key")))
+		headers := make(map[string]string)
+		for _, header := range message.Headers {
+			headers[string(header.Key)] = string(header.Value)
+		}
+		Expect(headers).To(Equal(map[string]string{
+			"trace-id":                        "abc",
+			webhook.DeadLetterStatusHeader:    "503",
+			webhook.DeadLetterAttemptsHeader:  "4",
+			webhook.DeadLetterErrorHeader:     "max retries reached after 4 attempts: perform request failed: status 503 != 2xx",
+			webhook.DeadLetterTopicHeader:     "my-topic",
+			webhook.DeadLetterPartitionHeader: "7",
+			webhook.DeadLetterOffsetHeader:    "42",
+			webhook.DeadLetterTimestampHeader: "2018-09-30T08:00:00Z",
+			webhook.DeadLetterFailedAtHeader:  "2018-10-01T12:00:00Z",
+		}))
+	})
+	It("returns error if send to dead letter topic fails", func() {
+		producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+		messageHandler.ConsumeMessageReturns(errors.New("banana"))
+
+		err := deadLetterMessageHandler.ConsumeMessage(context.Background(), msg)
+		Expect(err).NotTo(BeNil())
+	})
+	It("sends nothing if context is canceled", func() {
+		messageHandler.ConsumeMessageReturns(errors.New("banana"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := deadLetterMessageHandler.ConsumeMessage(ctx, msg)
+		Expect(err).NotTo(BeNil())
+		Expect(producer.messages).To(BeEmpty())
+	})
+})
@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Ed25519Signer signs with a private key, so receivers can verify without a shared secret.
+// Signatures are formatted as "<key id>=<base64url>", multiple signatures are separated by comma.
+type Ed25519Signer struct {
+	KeyID string
+	// PrivateKey used to sign, not required to verify
+	PrivateKey ed25519.PrivateKey
+	// PublicKeys accepted by key id, only the public key of PrivateKey if empty
+	PublicKeys map[string]ed25519.PublicKey
+}
+
+// Sign returns the signature of the private key.
+func (e *Ed25519Signer) Sign(content []byte) string {
+	return e.KeyID + "=" + base64.RawURLEncoding.EncodeToString(ed25519.Sign(e.PrivateKey, content))
+}
+
+// Compare returns true if any of the given signatures is valid for one of the public keys.
+func (e *Ed25519Signer) Compare(content []byte, sign string) (bool, error) {
+	publicKeys := e.publicKeys()
+	parsed := 0
+	for _, part := range splitList(sign) {
+		pos := strings.Index(part, "=")
+		if pos == -1 {
+			continue
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(part[pos+1:])
+		if err != nil {
+			continue
+		}
+		parsed++
+		publicKey, ok := publicKeys[part[:pos]]
+		if !ok {
+			continue
+		}
+		if ed25519.Verify(publicKey, content, signature) {
+			return true, nil
+		}
+	}
+	if parsed == 0 {
+		return false, errors.New("no valid signature found")
+	}
+	return false, nil
+}
+
+func (e *Ed25519Signer) publicKeys() map[string]ed25519.PublicKey {
+	if len(e.PublicKeys) > 0 || e.PrivateKey == nil {
+		return e.PublicKeys
+	}
+	return map[string]ed25519.PublicKey{
+		e.KeyID: e.PrivateKey.Public().(ed25519.PublicKey),
+	}
+}
+
+// ReadEd25519PrivateKey reads a PEM encoded PKCS #8 Ed25519 private key, as created by "openssl genpkey -algorithm ed25519".
+func ReadEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read private key %s failed", path)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.Errorf("no pem block found in %s", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse private key %s failed", path)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("private key %s is no ed25519 key", path)
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ed25519Signer", func() {
+	var publicKey ed25519.PublicKey
+	var signer *webhook.Ed25519Signer
+	content := []byte("banana")
+	BeforeEach(func() {
+		var privateKey ed25519.PrivateKey
+		var err error
+		publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).To(BeNil())
+		signer = &webhook.Ed25519Signer{
+			KeyID:      "k1",
+			PrivateKey: privateKey,
+		}
+	})
+	It("signs with key id", func() {
+		Expect(signer.Sign(content)).To(HavePrefix("k1="))
+	})
+	It("verifies own signature", func() {
+		equal, err := signer.Compare(content, signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeTrue())
+	})
+	It("verifies with public key only", func() {
+		verifier := &webhook.Ed25519Signer{
+			PublicKeys: map[string]ed25519.PublicKey{"k1": publicKey},
+		}
+		equal, err := verifier.Compare(content, signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeTrue())
+	})
+	It("rejects modified content", func() {
+		equal, err := signer.Compare([]byte("apple"), signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeFalse())
+	})
+	It("rejects signatures of unknown keys", func() {
+		verifier := &webhook.Ed25519Signer{
+			PublicKeys: map[string]ed25519.PublicKey{"k2": publicKey},
+		}
+		equal, err := verifier.Compare(content, signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeFalse())
+	})
+	It("returns error for invalid signatures", func() {
+		_, err := signer.Compare(content, "k1")
+		Expect(err).To(HaveOccurred())
+	})
+	It("reads pem private key", func() {
+		der, err := x509.MarshalPKCS8PrivateKey(signer.PrivateKey)
+		Expect(err).To(BeNil())
+		file, err := ioutil.TempFile("", "key")
+		Expect(err).To(BeNil())
+		defer os.Remove(file.Name())
+		Expect(pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der})).To(BeNil())
+		Expect(file.Close()).To(BeNil())
+
+		privateKey, err := webhook.ReadEd25519PrivateKey(file.Name())
+		Expect(err).To(BeNil())
+		Expect(privateKey).To(Equal(signer.PrivateKey))
+	})
+})
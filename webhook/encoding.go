@@ -0,0 +1,39 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"net/http"
+
+	"github.com/Shopify/sarama"
+)
+
+// Encodings of records as HTTP requests
+const (
+	// EncodingDefault sends X-Message-* headers and the X-Signature
+	EncodingDefault = "default"
+	// EncodingCloudEventsBinary sends CloudEvents 1.0 in HTTP binary mode
+	EncodingCloudEventsBinary = "cloudevents-binary"
+	// EncodingCloudEventsStructured sends CloudEvents 1.0 in structured JSON mode
+	EncodingCloudEventsStructured = "cloudevents-structured"
+	// EncodingStandardWebhooks sends requests as specified by Standard Webhooks
+	EncodingStandardWebhooks = "standard-webhooks"
+)
+
+// RequestCoder encodes records as HTTP requests and decodes them on the receiver side.
+type RequestCoder interface {
+	Encode(msg *sarama.ConsumerMessage) (*http.Request, error)
+	Decode(req *http.Request) (*sarama.ConsumerMessage, error)
+}
+
+// IsEncoding returns true if the given name is a known encoding. An empty name selects EncodingDefault.
+func IsEncoding(name string) bool {
+	switch name {
+	case "", EncodingDefault, EncodingCloudEventsBinary, EncodingCloudEventsStructured, EncodingStandardWebhooks:
+		return true
+	default:
+		return false
+	}
+}
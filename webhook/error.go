@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatusError is returned if the webhook responds with a status other than 2xx.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After header, zero if missing
+	RetryAfter time.Duration
+}
+
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("status %d != 2xx", s.StatusCode)
+}
+
+// MaxRetriesError is returned if a message could not be handled within the allowed amount of retries.
+type MaxRetriesError struct {
+	Attempts int
+	Err      error
+}
+
+func (m *MaxRetriesError) Error() string {
+	return fmt.Sprintf("max retries reached after %d attempts: %v", m.Attempts, m.Err)
+}
+
+// Cause returns the error of the last attempt.
+func (m *MaxRetriesError) Cause() error {
+	return m.Err
+}
+
+// RetryDeadlineError is returned if a message could not be handled within the retry deadline.
+type RetryDeadlineError struct {
+	Attempts int
+	Err      error
+}
+
+func (r *RetryDeadlineError) Error() string {
+	return fmt.Sprintf("retry deadline reached after %d attempts: %v", r.Attempts, r.Err)
+}
+
+// Cause returns the error of the last attempt.
+func (r *RetryDeadlineError) Cause() error {
+	return r.Err
+}
+
+// PermanentError is returned if a message can not be handled and retries are pointless.
+type PermanentError struct {
+	Attempts int
+	Err      error
+}
+
+func (p *PermanentError) Error() string {
+	return fmt.Sprintf("permanent failure after %d attempts: %v", p.Attempts, p.Err)
+}
+
+// Cause returns the error of the last attempt.
+func (p *PermanentError) Cause() error {
+	return p.Err
+}
+
+// attemptsOf returns the amount of attempts made to handle the message.
+func attemptsOf(err error) (int, bool) {
+	for _, cause := range causes(err) {
+		switch e := cause.(type) {
+		case *MaxRetriesError:
+			return e.Attempts, true
+		case *RetryDeadlineError:
+			return e.Attempts, true
+		case *PermanentError:
+			return e.Attempts, true
+		}
+	}
+	return 0, false
+}
+
+// statusErrorOf returns the StatusError in the cause chain of err or nil.
+func statusErrorOf(err error) *StatusError {
+	for _, cause := range causes(err) {
+		if statusError, ok := cause.(*StatusError); ok {
+			return statusError
+		}
+	}
+	return nil
+}
+
+// causes returns err followed by all errors of its cause chain.
+func causes(err error) []error {
+	var result []error
+	for err != nil {
+		result = append(result, err)
+		cause, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+	return result
+}
+
+// SignatureError is returned by Decode if the signature of a request is missing or invalid.
+type SignatureError struct {
+	Err error
+}
+
+func (s *SignatureError) Error() string {
+	return fmt.Sprintf("verify signature failed: %v", s.Err)
+}
+
+// Cause returns the reason the signature is invalid.
+func (s *SignatureError) Cause() error {
+	return s.Err
+}
+
+// TransformError is returned if the template of a Transform fails. Retries are pointless, the message is the same.
+type TransformError struct {
+	Err error
+}
+
+func (t *TransformError) Error() string {
+	return fmt.Sprintf("transform message failed: %v", t.Err)
+}
+
+// Cause returns the template error.
+func (t *TransformError) Cause() error {
+	return t.Err
+}
+
+// CircuitOpenError is returned instead of sending a request while the circuit of the host is open.
+type CircuitOpenError struct {
+	Host string
+	// RetryAfter is the time until the circuit allows the next request
+	RetryAfter time.Duration
+}
+
+func (c *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit of host %s open", c.Host)
+}
+
+// circuitOpenErrorOf returns the CircuitOpenError in the cause chain of err or nil.
+func circuitOpenErrorOf(err error) *CircuitOpenError {
+	for _, cause := range causes(err) {
+		if circuitOpenError, ok := cause.(*CircuitOpenError); ok {
+			return circuitOpenError
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+)
+
+// DefaultFanOutBuffer is the amount of messages a destination may lag behind the fastest one.
+const DefaultFanOutBuffer = 1000
+
+// FanOutProcessor delivers the messages of a partition to multiple destinations independently.
+// Every destination has its own Processor with its own retries and failure handling, a slow or failing
+// destination does not delay the others until it lags Buffer messages behind.
+// A message is marked after every destination marked it, so offsets are committed only if all
+// destinations reached a terminal state for all earlier messages.
+type FanOutProcessor struct {
+	Processors []Processor
+	// Buffer is the amount of messages a destination may lag behind, DefaultFanOutBuffer if zero
+	Buffer int
+}
+
+func (f *FanOutProcessor) Process(ctx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(msg *sarama.ConsumerMessage)) error {
+	watermark := &fanOutWatermark{
+		marked: make([]*sarama.ConsumerMessage, len(f.Processors)),
+		mark:   mark,
+	}
+	queues := make([]chan *sarama.ConsumerMessage, len(f.Processors))
+	errs := make([]error, len(f.Processors))
+	var wg sync.WaitGroup
+	for i, processor := range f.Processors {
+		queues[i] = make(chan *sarama.ConsumerMessage, f.buffer())
+		wg.Add(1)
+		go func(i int, processor Processor) {
+			defer wg.Done()
+			errs[i] = processor.Process(ctx, queues[i], func(msg *sarama.ConsumerMessage) {
+				watermark.finish(i, msg)
+			})
+			if errs[i] != nil {
+				glog.Warningf("destination %d stopped: %v", i, errs[i])
+			}
+		}(i, processor)
+	}
+	f.dispatch(ctx, messages, queues)
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch copies every message to all queues until messages is closed or ctx is done.
+func (f *FanOutProcessor) dispatch(ctx context.Context, messages <-chan *sarama.ConsumerMessage, queues []chan *sarama.ConsumerMessage) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok || ctx.Err() != nil {
+				return
+			}
+			for _, queue := range queues {
+				select {
+				case <-ctx.Done():
+					return
+				case queue <- msg:
+				}
+			}
+		}
+	}
+}
+
+func (f *FanOutProcessor) buffer() int {
+	if f.Buffer <= 0 {
+		return DefaultFanOutBuffer
+	}
+	return f.Buffer
+}
+
+// fanOutWatermark marks the lowest message marked by all destinations.
+type fanOutWatermark struct {
+	mux    sync.Mutex
+	marked []*sarama.ConsumerMessage
+	last   *sarama.ConsumerMessage
+	mark   func(msg *sarama.ConsumerMessage)
+}
+
+func (f *fanOutWatermark) finish(destination int, msg *sarama.ConsumerMessage) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.marked[destination] = msg
+	var lowest *sarama.ConsumerMessage
+	for _, marked := range f.marked {
+		if marked == nil {
+			return
+		}
+		if lowest == nil || marked.Offset < lowest.Offset {
+			lowest = marked
+		}
+	}
+	if f.last != nil && lowest.Offset <= f.last.Offset {
+		return
+	}
+	f.last = lowest
+	f.mark(lowest)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FanOutProcessor", func() {
+	var fast *mocks.Processor
+	var slow *mocks.Processor
+	var release chan struct{}
+	var processor *webhook.FanOutProcessor
+	var mux sync.Mutex
+	var marked []int64
+	mark := func(msg *sarama.ConsumerMessage) {
+		mux.Lock()
+		defer mux.Unlock()
+		marked = append(marked, msg.Offset)
+	}
+	markedOffsets := func() []int64 {
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]int64{}, marked...)
+	}
+	// deliver marks every message once it is received and release is closed
+	deliver := func(release <-chan struct{}) func(context.Context, <-chan *sarama.ConsumerMessage, func(*sarama.ConsumerMessage)) error {
+		return func(ctx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(*sarama.ConsumerMessage)) error {
+			for msg := range messages {
+				<-release
+				mark(msg)
+			}
+			return nil
+		}
+	}
+	BeforeEach(func() {
+		marked = nil
+		release = make(chan struct{})
+		released := make(chan struct{})
+		close(released)
+		fast = &mocks.Processor{}
+		fast.ProcessStub = deliver(released)
+		slow = &mocks.Processor{}
+		slow.ProcessStub = deliver(release)
+		processor = &webhook.FanOutProcessor{
+			Processors: []webhook.Processor{fast, slow},
+			Buffer:     10,
+		}
+	})
+	It("marks messages after all destinations marked them", func() {
+		messages := make(chan *sarama.ConsumerMessage, 3)
+		for offset := int64(1); offset <= 3; offset++ {
+			messages <- &sarama.ConsumerMessage{Offset: offset}
+		}
+		close(messages)
+		done := make(chan error, 1)
+		go func() {
+			done <- processor.Process(context.Background(), messages, mark)
+		}()
+		Consistently(markedOffsets).Should(BeEmpty())
+		close(release)
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(markedOffsets()).To(Equal([]int64{1, 2, 3}))
+	})
+	It("delivers to all destinations while one lags behind", func() {
+		var delivered []int64
+		var deliveredMux sync.Mutex
+		fast.ProcessStub = func(ctx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(*sarama.ConsumerMessage)) error {
+			for msg := range messages {
+				deliveredMux.Lock()
+				delivered = append(delivered, msg.Offset)
+				deliveredMux.Unlock()
+				mark(msg)
+			}
+			return nil
+		}
+		messages := make(chan *sarama.ConsumerMessage, 5)
+		for offset := int64(1); offset <= 5; offset++ {
+			messages <- &sarama.ConsumerMessage{Offset: offset}
+		}
+		close(messages)
+		done := make(chan error, 1)
+		go func() {
+			done <- processor.Process(context.Background(), messages, mark)
+		}()
+		Eventually(func() int {
+			deliveredMux.Lock()
+			defer deliveredMux.Unlock()
+			return len(delivered)
+		}).Should(Equal(5))
+		Expect(markedOffsets()).To(BeEmpty())
+		close(release)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+	It("returns the error of a destination", func() {
+		close(release)
+		slow.ProcessReturns(context.Canceled)
+		messages := make(chan *sarama.ConsumerMessage)
+		close(messages)
+		Expect(processor.Process(context.Background(), messages, mark)).To(Equal(context.Canceled))
+		Expect(fast.ProcessCallCount()).To(Equal(1))
+	})
+})
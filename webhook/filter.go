@@ -0,0 +1,471 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Filter selects the messages to deliver with an expression over key, headers and JSON value fields.
+//
+//	header.type == "order" && (value.amount >= 100 || exists(value.priority)) && !(key =~ '^test-')
+//
+// Fields are key, topic, value, header.<name> and value.<path> with path segments separated by dots,
+// array elements are selected by index. Comparisons with a missing field are false, test with exists().
+// Strings are written in double quotes with escapes or single quotes without.
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+// ParseFilter parses the given expression. An empty expression returns nil, nil matches every message.
+func ParseFilter(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse filter %s failed", expression)
+	}
+	parser := &filterParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err == nil && parser.peek().kind != filterTokenEnd {
+		err = errors.Errorf("unexpected %s", parser.peek())
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse filter %s failed", expression)
+	}
+	return &Filter{
+		expression: expression,
+		root:       root,
+	}, nil
+}
+
+// Match returns true if the message should be delivered.
+func (f *Filter) Match(msg *sarama.ConsumerMessage) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.eval(&filterMessage{msg: msg})
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expression
+}
+
+// filterMessage decodes the JSON value once per message on first use.
+type filterMessage struct {
+	msg     *sarama.ConsumerMessage
+	decoded bool
+	value   interface{}
+	valid   bool
+}
+
+func (m *filterMessage) field(path filterPath) (string, bool) {
+	switch path.root {
+	case "key":
+		return string(m.msg.Key), m.msg.Key != nil
+	case "topic":
+		return m.msg.Topic, true
+	case "header":
+		for _, header := range m.msg.Headers {
+			if header != nil && string(header.Key) == path.name {
+				return string(header.Value), true
+			}
+		}
+		return "", false
+	}
+	if len(path.segments) == 0 {
+		return string(m.msg.Value), m.msg.Value != nil
+	}
+	if !m.decoded {
+		m.decoded = true
+		decoder := json.NewDecoder(bytes.NewReader(m.msg.Value))
+		decoder.UseNumber()
+		m.valid = decoder.Decode(&m.value) == nil
+	}
+	if !m.valid {
+		return "", false
+	}
+	current := m.value
+	for _, segment := range path.segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return "", false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			current = node[index]
+		default:
+			return "", false
+		}
+	}
+	return jsonString(current), true
+}
+
+// jsonString returns strings unquoted and all other JSON values as JSON.
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		content, _ := json.Marshal(v)
+		return string(content)
+	}
+}
+
+type filterNode interface {
+	eval(msg *filterMessage) bool
+}
+
+type filterAnd struct {
+	left, right filterNode
+}
+
+func (f *filterAnd) eval(msg *filterMessage) bool {
+	return f.left.eval(msg) && f.right.eval(msg)
+}
+
+type filterOr struct {
+	left, right filterNode
+}
+
+func (f *filterOr) eval(msg *filterMessage) bool {
+	return f.left.eval(msg) || f.right.eval(msg)
+}
+
+type filterNot struct {
+	node filterNode
+}
+
+func (f *filterNot) eval(msg *filterMessage) bool {
+	return !f.node.eval(msg)
+}
+
+type filterExists struct {
+	path filterPath
+}
+
+func (f *filterExists) eval(msg *filterMessage) bool {
+	_, ok := msg.field(f.path)
+	return ok
+}
+
+type filterCompare struct {
+	path    filterPath
+	op      string
+	text    string
+	number  float64
+	numeric bool
+	regexp  *regexp.Regexp
+}
+
+func (f *filterCompare) eval(msg *filterMessage) bool {
+	value, ok := msg.field(f.path)
+	if !ok {
+		return false
+	}
+	switch f.op {
+	case "=~":
+		return f.regexp.MatchString(value)
+	case "!~":
+		return !f.regexp.MatchString(value)
+	}
+	if !f.numeric {
+		if f.op == "==" {
+			return value == f.text
+		}
+		return value != f.text
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	switch f.op {
+	case "==":
+		return number == f.number
+	case "!=":
+		return number != f.number
+	case ">":
+		return number > f.number
+	case ">=":
+		return number >= f.number
+	case "<":
+		return number < f.number
+	default:
+		return number <= f.number
+	}
+}
+
+// filterPath is a parsed field like header.type or value.customer.id
+type filterPath struct {
+	root     string
+	name     string
+	segments []string
+}
+
+func parseFilterPath(ident string) (filterPath, error) {
+	parts := strings.Split(ident, ".")
+	switch parts[0] {
+	case "key", "topic":
+		if len(parts) > 1 {
+			return filterPath{}, errors.Errorf("field %s has no fields", parts[0])
+		}
+		return filterPath{root: parts[0]}, nil
+	case "header":
+		if len(parts) < 2 || parts[1] == "" {
+			return filterPath{}, errors.New("header name missing")
+		}
+		return filterPath{root: "header", name: strings.Join(parts[1:], ".")}, nil
+	case "value":
+		for _, segment := range parts[1:] {
+			if segment == "" {
+				return filterPath{}, errors.Errorf("field %s invalid", ident)
+			}
+		}
+		return filterPath{root: "value", segments: parts[1:]}, nil
+	}
+	return filterPath{}, errors.Errorf("field %s unknown, expected key, topic, header.<name> or value.<path>", ident)
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenEnd filterTokenKind = iota
+	filterTokenIdent
+	filterTokenString
+	filterTokenNumber
+	filterTokenOp
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	value string
+}
+
+func (t filterToken) String() string {
+	if t.kind == filterTokenEnd {
+		return "end of filter"
+	}
+	return strconv.Quote(t.value)
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "=~", "!~", ">=", "<=", ">", "<", "!", "(", ")"}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expression)
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case r == '"':
+			end := pos + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			value, err := strconv.Unquote(string(runes[pos : end+1]))
+			if err != nil {
+				return nil, errors.Wrapf(err, "string %s invalid", string(runes[pos:end+1]))
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, value: value})
+			pos = end + 1
+		case r == '\'':
+			end := pos + 1
+			for ; end < len(runes) && runes[end] != '\''; end++ {
+			}
+			if end >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, value: string(runes[pos+1 : end])})
+			pos = end + 1
+		case unicode.IsDigit(r) || r == '-' && pos+1 < len(runes) && unicode.IsDigit(runes[pos+1]):
+			end := pos + 1
+			for ; end < len(runes) && (unicode.IsDigit(runes[end]) || strings.ContainsRune(".eE+-", runes[end])); end++ {
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, value: string(runes[pos:end])})
+			pos = end
+		case unicode.IsLetter(r) || r == '_':
+			end := pos + 1
+			for ; end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || strings.ContainsRune("_-.", runes[end])); end++ {
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenIdent, value: string(runes[pos:end])})
+			pos = end
+		default:
+			var op string
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(string(runes[pos:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenOp, value: op})
+			pos += len([]rune(op))
+		}
+	}
+	return append(tokens, filterToken{kind: filterTokenEnd}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	token := p.tokens[p.pos]
+	if token.kind != filterTokenEnd {
+		p.pos++
+	}
+	return token
+}
+
+func (p *filterParser) expect(op string) error {
+	if token := p.next(); token.kind != filterTokenOp || token.value != op {
+		return errors.Errorf("expected %q but got %s", op, token)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == filterTokenOp && p.peek().value == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == filterTokenOp && p.peek().value == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	token := p.next()
+	switch {
+	case token.kind == filterTokenOp && token.value == "!":
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{node: node}, nil
+	case token.kind == filterTokenOp && token.value == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case token.kind == filterTokenIdent && token.value == "exists":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		ident := p.next()
+		if ident.kind != filterTokenIdent {
+			return nil, errors.Errorf("expected field but got %s", ident)
+		}
+		path, err := parseFilterPath(ident.value)
+		if err != nil {
+			return nil, err
+		}
+		return &filterExists{path: path}, p.expect(")")
+	case token.kind == filterTokenIdent:
+		path, err := parseFilterPath(token.value)
+		if err != nil {
+			return nil, err
+		}
+		return p.parseCompare(path)
+	}
+	return nil, errors.Errorf("expected field, exists, ! or ( but got %s", token)
+}
+
+func (p *filterParser) parseCompare(path filterPath) (filterNode, error) {
+	op := p.next()
+	if op.kind != filterTokenOp {
+		return nil, errors.Errorf("expected comparison but got %s", op)
+	}
+	literal := p.next()
+	compare := &filterCompare{path: path, op: op.value, text: literal.value}
+	switch op.value {
+	case "=~", "!~":
+		if literal.kind != filterTokenString {
+			return nil, errors.Errorf("%s expects a string but got %s", op.value, literal)
+		}
+		var err error
+		if compare.regexp, err = regexp.Compile(literal.value); err != nil {
+			return nil, errors.Wrapf(err, "regexp %s invalid", literal.value)
+		}
+		return compare, nil
+	case "==", "!=":
+		switch {
+		case literal.kind == filterTokenString:
+			return compare, nil
+		case literal.kind == filterTokenIdent && (literal.value == "true" || literal.value == "false" || literal.value == "null"):
+			return compare, nil
+		}
+	case ">", ">=", "<", "<=":
+	default:
+		return nil, errors.Errorf("expected comparison but got %s", op)
+	}
+	if literal.kind != filterTokenNumber {
+		return nil, errors.Errorf("%s expects a number but got %s", op.value, literal)
+	}
+	number, err := strconv.ParseFloat(literal.value, 64)
+	if err != nil {
+		return nil, errors.Errorf("number %s invalid", literal.value)
+	}
+	compare.number = number
+	compare.numeric = true
+	return compare, nil
+}
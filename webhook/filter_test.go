@@ -0,0 +1,100 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+	var msg *sarama.ConsumerMessage
+	BeforeEach(func() {
+		msg = &sarama.ConsumerMessage{
+			Topic: "orders",
+			Key:   []byte("order-1"),
+			Value: []byte(`{"amount":150,"paid":true,"customer":{"id":"c1"},"items":[{"sku":"a"}]}`),
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("type"), Value: []byte("order.created")},
+			},
+		}
+	})
+	match := func(expression string) bool {
+		filter, err := webhook.ParseFilter(expression)
+		Expect(err).To(BeNil(), expression)
+		return filter.Match(msg)
+	}
+	It("matches everything without expression", func() {
+		filter, err := webhook.ParseFilter(" ")
+		Expect(err).To(BeNil())
+		Expect(filter).To(BeNil())
+		Expect(filter.Match(msg)).To(BeTrue())
+	})
+	It("compares key, topic and headers", func() {
+		Expect(match(`key == "order-1"`)).To(BeTrue())
+		Expect(match(`key != "order-1"`)).To(BeFalse())
+		Expect(match(`topic == 'orders'`)).To(BeTrue())
+		Expect(match(`header.type == "order.created"`)).To(BeTrue())
+		Expect(match(`header.type == "order.deleted"`)).To(BeFalse())
+	})
+	It("matches regular expressions", func() {
+		Expect(match(`header.type =~ '^order\.'`)).To(BeTrue())
+		Expect(match(`key !~ "^order-"`)).To(BeFalse())
+	})
+	It("tests existence", func() {
+		Expect(match(`exists(header.type)`)).To(BeTrue())
+		Expect(match(`exists(header.trace)`)).To(BeFalse())
+		Expect(match(`exists(value.customer.id)`)).To(BeTrue())
+		Expect(match(`exists(value.customer.name)`)).To(BeFalse())
+	})
+	It("compares json fields", func() {
+		Expect(match(`value.amount > 100`)).To(BeTrue())
+		Expect(match(`value.amount <= 100`)).To(BeFalse())
+		Expect(match(`value.amount == 150`)).To(BeTrue())
+		Expect(match(`value.amount == 1.5e2`)).To(BeTrue())
+		Expect(match(`value.paid == true`)).To(BeTrue())
+		Expect(match(`value.customer.id == "c1"`)).To(BeTrue())
+		Expect(match(`value.items.0.sku == "a"`)).To(BeTrue())
+		Expect(match(`value.items.1.sku == "a"`)).To(BeFalse())
+	})
+	It("is false for missing fields", func() {
+		Expect(match(`value.missing != "a"`)).To(BeFalse())
+		Expect(match(`header.missing > 1`)).To(BeFalse())
+		Expect(match(`value.customer > 1`)).To(BeFalse())
+	})
+	It("is false for json fields of invalid json", func() {
+		msg.Value = []byte("banana")
+		Expect(match(`exists(value.amount)`)).To(BeFalse())
+		Expect(match(`value == "banana"`)).To(BeTrue())
+	})
+	It("combines expressions", func() {
+		Expect(match(`key == "order-1" && value.amount > 200`)).To(BeFalse())
+		Expect(match(`key == "order-1" && value.amount > 200 || exists(header.type)`)).To(BeTrue())
+		Expect(match(`key == "order-1" && (value.amount > 200 || !exists(header.type))`)).To(BeFalse())
+		Expect(match(`!(value.amount < 100)`)).To(BeTrue())
+	})
+	It("returns error for invalid expressions", func() {
+		for _, expression := range []string{
+			`key`,
+			`key ==`,
+			`key == "a" &&`,
+			`(key == "a"`,
+			`body.amount > 1`,
+			`header. == "a"`,
+			`key.name == "a"`,
+			`value.amount > "1"`,
+			`key =~ 1`,
+			`key =~ "("`,
+			`key == "a`,
+			`key == "a" key == "b"`,
+			`key # "a"`,
+		} {
+			_, err := webhook.ParseFilter(expression)
+			Expect(err).NotTo(BeNil(), expression)
+		}
+	})
+})
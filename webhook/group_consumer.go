@@ -0,0 +1,285 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// DefaultDrainTimeout is the time in-flight deliveries may take after partitions are revoked.
+const DefaultDrainTimeout = 30 * time.Second
+
+// GroupConsumer delivers the records of topics as member of a kafka consumer group.
+// Partitions are balanced between all members, so multiple instances with the same group deliver every record once.
+type GroupConsumer struct {
+	KafkaBrokers string
+	// KafkaTopic is a comma separated list of topics
+	KafkaTopic string
+	// KafkaTopicPattern subscribes all topics matching the regular expression in addition to KafkaTopic
+	KafkaTopicPattern string
+	// TopicRefreshInterval is the interval new topics matching KafkaTopicPattern are looked up, DefaultTopicRefreshInterval if zero
+	TopicRefreshInterval time.Duration
+	KafkaGroup           string
+	// Security configures TLS and SASL, plaintext if nil
+	Security *KafkaSecurity
+	// StartPosition of partitions without committed offset, oldest if nil
+	StartPosition *StartPosition
+	// Processor delivers the records of every claimed partition
+	Processor Processor
+}
+
+func (g *GroupConsumer) Consume(ctx context.Context) error {
+	selector, err := NewTopicSelector(g.KafkaTopic, g.KafkaTopicPattern)
+	if err != nil {
+		return err
+	}
+	glog.V(0).Infof("consume topic %s with group %s started", selector, g.KafkaGroup)
+	defer glog.V(0).Infof("consume topic %s with group %s finished", selector, g.KafkaGroup)
+
+	client, err := g.createClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	group, err := sarama.NewConsumerGroupFromClient(g.KafkaGroup, client)
+	if err != nil {
+		return errors.Wrapf(err, "create consumer group %s with brokers %s failed", g.KafkaGroup, g.KafkaBrokers)
+	}
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			glog.Warningf("consume topic %s with group %s failed: %v", selector, g.KafkaGroup, err)
+		}
+	}()
+
+	handler := &GroupHandler{
+		Processor:     g.Processor,
+		KafkaGroup:    g.KafkaGroup,
+		StartPosition: g.startPosition(),
+		Offsets:       &ClientOffsetSource{Client: client},
+	}
+	for {
+		topics, err := g.topics(client, selector)
+		if err != nil {
+			return err
+		}
+		if len(topics) == 0 {
+			glog.Warningf("no topic matches %s, look up again in %v", selector, g.topicRefreshInterval())
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(g.topicRefreshInterval()):
+				continue
+			}
+		}
+		consumeCtx, cancel := context.WithCancel(ctx)
+		if selector.Dynamic() {
+			go g.watchTopics(consumeCtx, cancel, client, selector, topics)
+		}
+		// Consume returns after every rebalance and must be called again to join the next generation
+		err = group.Consume(consumeCtx, topics, handler)
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "consume topic %s with group %s failed", selector, g.KafkaGroup)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// ResetOffsets moves the committed offsets of all partitions of the selected topics to the StartPosition.
+// It must run while no member of the group consumes, e.g. as a one-off job before the consumers are started.
+func (g *GroupConsumer) ResetOffsets(ctx context.Context) error {
+	selector, err := NewTopicSelector(g.KafkaTopic, g.KafkaTopicPattern)
+	if err != nil {
+		return err
+	}
+	client, err := g.createClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	topics, err := g.topics(client, selector)
+	if err != nil {
+		return err
+	}
+	if len(topics) == 0 {
+		return errors.Errorf("no topic matches %s", selector)
+	}
+	partitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		if partitions[topic], err = client.Partitions(topic); err != nil {
+			return errors.Wrapf(err, "get partitions of topic %s failed", topic)
+		}
+	}
+	offsetReset := &OffsetReset{
+		KafkaGroup:    g.KafkaGroup,
+		StartPosition: g.startPosition(),
+		Offsets:       &ClientOffsetSource{Client: client},
+		Committer:     &OffsetManagerCommitter{Client: client},
+	}
+	return offsetReset.Reset(partitions)
+}
+
+func (g *GroupConsumer) createClient() (sarama.Client, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_0_0_0
+	config.Consumer.Offsets.Initial = g.startPosition().Initial
+	config.Consumer.Return.Errors = true
+	if err := g.Security.Apply(config); err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(strings.Split(g.KafkaBrokers, ","), config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create client with brokers %s failed", g.KafkaBrokers)
+	}
+	return client, nil
+}
+
+// topics returns the topics to subscribe, with a pattern after refreshing the metadata of all topics.
+func (g *GroupConsumer) topics(client sarama.Client, selector *TopicSelector) ([]string, error) {
+	if !selector.Dynamic() {
+		return selector.Select(nil), nil
+	}
+	if err := client.RefreshMetadata(); err != nil {
+		return nil, errors.Wrap(err, "refresh metadata failed")
+	}
+	available, err := client.Topics()
+	if err != nil {
+		return nil, errors.Wrap(err, "list topics failed")
+	}
+	return selector.Select(available), nil
+}
+
+// watchTopics calls cancel to rejoin the group if the topics matching the selector changed.
+func (g *GroupConsumer) watchTopics(ctx context.Context, cancel context.CancelFunc, client sarama.Client, selector *TopicSelector, current []string) {
+	ticker := time.NewTicker(g.topicRefreshInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			topics, err := g.topics(client, selector)
+			if err != nil {
+				glog.Warningf("look up topics %s failed: %v", selector, err)
+				continue
+			}
+			if !reflect.DeepEqual(topics, current) {
+				glog.V(0).Infof("topics of %s changed from %v to %v, rejoin group %s", selector, current, topics, g.KafkaGroup)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (g *GroupConsumer) topicRefreshInterval() time.Duration {
+	if g.TopicRefreshInterval <= 0 {
+		return DefaultTopicRefreshInterval
+	}
+	return g.TopicRefreshInterval
+}
+
+func (g *GroupConsumer) startPosition() *StartPosition {
+	if g.StartPosition == nil {
+		return &StartPosition{Initial: sarama.OffsetOldest}
+	}
+	return g.StartPosition
+}
+
+// GroupHandler delivers the records of the claimed partitions and marks them consumed.
+// If partitions are revoked, in-flight deliveries are drained before the marked offsets are committed.
+type GroupHandler struct {
+	// Processor delivers the records of every claimed partition
+	Processor Processor
+	// KafkaGroup is the group to look up committed offsets for
+	KafkaGroup string
+	// StartPosition of partitions without committed offset. Only needed if Explicit,
+	// otherwise sarama's Consumer.Offsets.Initial applies
+	StartPosition *StartPosition
+	// Offsets looks up offsets if StartPosition is Explicit
+	Offsets OffsetSource
+}
+
+func (g *GroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	glog.V(1).Infof("generation %d claimed partitions %v", session.GenerationID(), session.Claims())
+	if g.StartPosition == nil || !g.StartPosition.Explicit() {
+		return nil
+	}
+	for topic, partitions := range session.Claims() {
+		if err := g.setupTopic(session, topic, partitions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupTopic moves the partitions without committed offset to the StartPosition.
+func (g *GroupHandler) setupTopic(session sarama.ConsumerGroupSession, topic string, partitions []int32) error {
+	committed, err := g.Offsets.CommittedOffsets(g.KafkaGroup, topic, partitions)
+	if err != nil {
+		return err
+	}
+	for _, partition := range partitions {
+		if committed[partition] >= 0 {
+			continue
+		}
+		offset, err := g.StartPosition.Resolve(topic, partition, g.Offsets.GetOffset)
+		if err != nil {
+			return err
+		}
+		glog.V(0).Infof("start topic %s partition %d of group %s at offset %d (committed %d)", topic, partition, g.KafkaGroup, offset, committed[partition])
+		// ResetOffset only moves backward and MarkOffset only forward
+		session.ResetOffset(topic, partition, offset, "")
+		session.MarkOffset(topic, partition, offset, "")
+	}
+	return nil
+}
+
+func (g *GroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	glog.V(1).Infof("generation %d released partitions %v", session.GenerationID(), session.Claims())
+	return nil
+}
+
+func (g *GroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	glog.V(1).Infof("consume topic %s partition %d started", claim.Topic(), claim.Partition())
+	defer glog.V(1).Infof("consume topic %s partition %d finished", claim.Topic(), claim.Partition())
+
+	return g.Processor.Process(session.Context(), claim.Messages(), func(msg *sarama.ConsumerMessage) {
+		session.MarkMessage(msg, "")
+	})
+}
+
+// drainContext returns a context that is canceled the given timeout after ctx is done.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-drainCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-drainCtx.Done():
+		case <-timer.C:
+			cancel()
+		}
+	}()
+	return drainCtx, cancel
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GroupHandler", func() {
+	var handler *webhook.GroupHandler
+	var processor *webhook.PartitionProcessor
+	var messageHandler *mocks.MessageHandler
+	var session *testSession
+	var claim *testClaim
+	var cancel context.CancelFunc
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		session = &testSession{ctx: ctx}
+		claim = &testClaim{messages: make(chan *sarama.ConsumerMessage, 10)}
+		messageHandler = &mocks.MessageHandler{}
+		processor = &webhook.PartitionProcessor{
+			MessageHandler: messageHandler,
+			DrainTimeout:   time.Second,
+		}
+		handler = &webhook.GroupHandler{
+			Processor: processor,
+		}
+	})
+	AfterEach(func() {
+		cancel()
+	})
+	It("marks delivered messages", func() {
+		claim.messages <- &sarama.ConsumerMessage{Offset: 1}
+		claim.messages <- &sarama.ConsumerMessage{Offset: 2}
+		close(claim.messages)
+		Expect(handler.ConsumeClaim(session, claim)).To(BeNil())
+		Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(2))
+		Expect(session.markedOffsets()).To(Equal([]int64{1, 2}))
+	})
+	It("does not mark failed messages", func() {
+		messageHandler.ConsumeMessageReturnsOnCall(0, errors.New("banana"))
+		claim.messages <- &sarama.ConsumerMessage{Offset: 1}
+		claim.messages <- &sarama.ConsumerMessage{Offset: 2}
+		close(claim.messages)
+		Expect(handler.ConsumeClaim(session, claim)).To(BeNil())
+		Expect(session.markedOffsets()).To(Equal([]int64{2}))
+	})
+	It("drains the in-flight delivery if partitions are revoked", func() {
+		messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+			return ctx.Err()
+		}
+		claim.messages <- &sarama.ConsumerMessage{Offset: 1}
+		claim.messages <- &sarama.ConsumerMessage{Offset: 2}
+		Expect(handler.ConsumeClaim(session, claim)).To(BeNil())
+		Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(1))
+		Expect(session.markedOffsets()).To(Equal([]int64{1}))
+	})
+	It("does not mark the in-flight delivery if draining times out", func() {
+		processor.DrainTimeout = 10 * time.Millisecond
+		messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+			cancel()
+			<-ctx.Done()
+			return nil
+		}
+		claim.messages <- &sarama.ConsumerMessage{Offset: 1}
+		Expect(handler.ConsumeClaim(session, claim)).To(BeNil())
+		Expect(session.markedOffsets()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("GroupHandler Setup", func() {
+	var handler *webhook.GroupHandler
+	var offsetSource *mocks.OffsetSource
+	var session *testSession
+	BeforeEach(func() {
+		session = &testSession{
+			ctx:    context.Background(),
+			claims: map[string][]int32{"topic": {0, 1}},
+		}
+		offsetSource = &mocks.OffsetSource{}
+		offsetSource.CommittedOffsetsReturns(map[int32]int64{0: -1, 1: 7}, nil)
+		offsetSource.GetOffsetStub = func(topic string, partition int32, time int64) (int64, error) {
+			return 100 + int64(partition), nil
+		}
+		handler = &webhook.GroupHandler{
+			KafkaGroup: "group",
+			Offsets:    offsetSource,
+		}
+	})
+	It("leaves the start to sarama without explicit position", func() {
+		handler.StartPosition = &webhook.StartPosition{Initial: sarama.OffsetNewest}
+		Expect(handler.Setup(session)).To(BeNil())
+		Expect(offsetSource.CommittedOffsetsCallCount()).To(Equal(0))
+		Expect(session.offsets).To(BeEmpty())
+	})
+	It("starts partitions without committed offset at the explicit position", func() {
+		handler.StartPosition = &webhook.StartPosition{Initial: sarama.OffsetOldest, Offsets: map[int32]int64{0: 5}}
+		Expect(handler.Setup(session)).To(BeNil())
+		group, topic, _ := offsetSource.CommittedOffsetsArgsForCall(0)
+		Expect(group).To(Equal("group"))
+		Expect(topic).To(Equal("topic"))
+		Expect(session.offsets).To(Equal(map[int32]int64{0: 5}))
+	})
+	It("keeps committed offsets", func() {
+		handler.StartPosition = &webhook.StartPosition{Initial: sarama.OffsetOldest, Offsets: map[int32]int64{0: 5, 1: 6}}
+		Expect(handler.Setup(session)).To(BeNil())
+		Expect(session.offsets).To(Equal(map[int32]int64{0: 5}))
+	})
+	It("returns error if committed offsets are not available", func() {
+		handler.StartPosition = &webhook.StartPosition{Initial: sarama.OffsetOldest, Offsets: map[int32]int64{0: 5}}
+		offsetSource.CommittedOffsetsReturns(nil, errors.New("banana"))
+		Expect(handler.Setup(session)).NotTo(BeNil())
+	})
+})
+
+type testSession struct {
+	sarama.ConsumerGroupSession
+	ctx     context.Context
+	claims  map[string][]int32
+	mux     sync.Mutex
+	marked  []int64
+	offsets map[int32]int64
+}
+
+func (t *testSession) Claims() map[string][]int32 {
+	return t.claims
+}
+
+func (t *testSession) GenerationID() int32 {
+	return 1
+}
+
+func (t *testSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	t.setOffset(partition, offset)
+}
+
+func (t *testSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	t.setOffset(partition, offset)
+}
+
+func (t *testSession) setOffset(partition int32, offset int64) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.offsets == nil {
+		t.offsets = make(map[int32]int64)
+	}
+	t.offsets[partition] = offset
+}
+
+func (t *testSession) Context() context.Context {
+	return t.ctx
+}
+
+func (t *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.marked = append(t.marked, msg.Offset)
+}
+
+func (t *testSession) markedOffsets() []int64 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.marked
+}
+
+type testClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (t *testClaim) Topic() string {
+	return "topic"
+}
+
+func (t *testClaim) Partition() int32 {
+	return 0
+}
+
+func (t *testClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return t.messages
+}
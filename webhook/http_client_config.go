@@ -0,0 +1,220 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Defaults of the HttpClientConfig.
+const (
+	DefaultHttpDialTimeout         = 30 * time.Second
+	DefaultHttpTLSHandshakeTimeout = 10 * time.Second
+	DefaultHttpKeepAlive           = 30 * time.Second
+	DefaultHttpIdleConnTimeout     = 90 * time.Second
+	DefaultHttpMaxIdleConnsPerHost = 10
+)
+
+// HttpClientConfig configures the connections to the webhooks. The request timeout is the HookTimeout of a route.
+type HttpClientConfig struct {
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes, disabled if negative
+	KeepAlive           time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	// Proxy is the url of the HTTP proxy, the environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if empty
+	Proxy string
+	// HTTP2 negotiates HTTP/2 with servers supporting it
+	HTTP2 bool
+	// TLSCA is a pem file with the CA bundle to verify webhooks, system roots if empty
+	TLSCA string
+	// TLSCert and TLSKey are pem files of the client certificate
+	TLSCert string
+	TLSKey  string
+	// TLSReloadInterval is the interval the client certificate is reloaded, never if zero
+	TLSReloadInterval time.Duration
+}
+
+// Validate returns all problems of the settings at once.
+func (h *HttpClientConfig) Validate() []error {
+	var errs []error
+	if h.DialTimeout < 0 || h.TLSHandshakeTimeout < 0 || h.IdleConnTimeout < 0 || h.TLSReloadInterval < 0 {
+		errs = append(errs, errors.New("HttpClient timeouts invalid"))
+	}
+	if h.MaxIdleConnsPerHost < 0 {
+		errs = append(errs, errors.New("HttpMaxIdleConnsPerHost invalid"))
+	}
+	if h.Proxy != "" {
+		if u, err := url.Parse(h.Proxy); err != nil || u.Host == "" {
+			errs = append(errs, errors.Errorf("HttpProxy %s invalid", h.Proxy))
+		}
+	}
+	if (h.TLSCert == "") != (h.TLSKey == "") {
+		errs = append(errs, errors.New("HttpTLSCert and HttpTLSKey must be given together"))
+	}
+	return errs
+}
+
+// ConfiguredHttpClient sends requests with the transport defined by a HttpClientConfig.
+type ConfiguredHttpClient struct {
+	client      *http.Client
+	transport   *http.Transport
+	certificate *ClientCertificate
+	interval    time.Duration
+}
+
+// NewHttpClient returns the client of the config. Files are read immediately.
+func NewHttpClient(config *HttpClientConfig) (*ConfiguredHttpClient, error) {
+	if errs := config.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	tlsConfig := &tls.Config{}
+	if config.TLSCA != "" {
+		content, err := ioutil.ReadFile(config.TLSCA)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read ca %s failed", config.TLSCA)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, errors.Errorf("no certificates found in %s", config.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	var certificate *ClientCertificate
+	if config.TLSCert != "" {
+		certificate = &ClientCertificate{
+			CertFile: config.TLSCert,
+			KeyFile:  config.TLSKey,
+		}
+		if _, err := certificate.Load(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = certificate.GetClientCertificate
+	}
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse proxy %s failed", config.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: config.KeepAlive,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:   config.HTTP2,
+	}
+	if !config.HTTP2 {
+		// a non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &ConfiguredHttpClient{
+		client: &http.Client{
+			Transport: transport,
+		},
+		transport:   transport,
+		certificate: certificate,
+		interval:    config.TLSReloadInterval,
+	}, nil
+}
+
+func (c *ConfiguredHttpClient) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req)
+}
+
+// Run reloads the client certificate every interval until the context is done.
+// Idle connections are closed after a change, so new requests use the new certificate.
+func (c *ConfiguredHttpClient) Run(ctx context.Context) error {
+	if c.certificate == nil || c.interval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := c.certificate.Load()
+			if err != nil {
+				glog.Warningf("reload client certificate failed: %v", err)
+				continue
+			}
+			if changed {
+				c.transport.CloseIdleConnections()
+				glog.V(0).Infof("client certificate %s reloaded", c.certificate.CertFile)
+			}
+		}
+	}
+}
+
+// ClientCertificate holds the last certificate loaded from CertFile and KeyFile.
+type ClientCertificate struct {
+	CertFile string
+	KeyFile  string
+
+	mux         sync.RWMutex
+	certificate *tls.Certificate
+	content     []byte
+}
+
+// Load reads the certificate and returns true if it changed. The previous certificate is kept on errors.
+func (c *ClientCertificate) Load() (bool, error) {
+	certPEM, err := ioutil.ReadFile(c.CertFile)
+	if err != nil {
+		return false, errors.Wrapf(err, "read certificate %s failed", c.CertFile)
+	}
+	keyPEM, err := ioutil.ReadFile(c.KeyFile)
+	if err != nil {
+		return false, errors.Wrapf(err, "read key %s failed", c.KeyFile)
+	}
+	content := append(append([]byte{}, certPEM...), keyPEM...)
+	c.mux.RLock()
+	unchanged := bytes.Equal(c.content, content)
+	c.mux.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, errors.Wrapf(err, "load key pair %s and %s failed", c.CertFile, c.KeyFile)
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.certificate = &certificate
+	c.content = content
+	return true, nil
+}
+
+// GetClientCertificate returns the current certificate, it is used as tls.Config.GetClientCertificate.
+func (c *ClientCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if c.certificate == nil {
+		return nil, errors.Errorf("certificate %s not loaded", c.CertFile)
+	}
+	return c.certificate, nil
+}
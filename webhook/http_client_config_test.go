@@ -0,0 +1,132 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HttpClientConfig", func() {
+	var dir string
+	var server *httptest.Server
+	var config *webhook.HttpClientConfig
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "http-client")
+		Expect(err).To(BeNil())
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+		server.EnableHTTP2 = true
+		config = &webhook.HttpClientConfig{
+			TLSCA: filepath.Join(dir, "ca.pem"),
+		}
+	})
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+	start := func() {
+		server.StartTLS()
+		content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		Expect(ioutil.WriteFile(config.TLSCA, content, 0600)).To(BeNil())
+	}
+	get := func() *http.Response {
+		client, err := webhook.NewHttpClient(config)
+		Expect(err).To(BeNil())
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		Expect(err).To(BeNil())
+		resp, err := client.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		return resp
+	}
+	It("validates", func() {
+		Expect(config.Validate()).To(BeEmpty())
+		config.TLSCert = "cert.pem"
+		config.Proxy = "no url"
+		Expect(config.Validate()).To(HaveLen(2))
+	})
+	It("verifies the server with the ca", func() {
+		start()
+		Expect(get().StatusCode).To(Equal(http.StatusOK))
+	})
+	It("returns error for a missing ca", func() {
+		_, err := webhook.NewHttpClient(config)
+		Expect(err).NotTo(BeNil())
+	})
+	It("uses http2 if enabled", func() {
+		start()
+		config.HTTP2 = true
+		Expect(get().ProtoMajor).To(Equal(2))
+	})
+	It("uses http1 if http2 is disabled", func() {
+		start()
+		Expect(get().ProtoMajor).To(Equal(1))
+	})
+	It("sends the client certificate", func() {
+		// the handshake fails without client certificate
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		start()
+		config.TLSCert = filepath.Join(dir, "client.pem")
+		config.TLSKey = filepath.Join(dir, "client-key.pem")
+		writeTestCertificate(config.TLSCert, config.TLSKey)
+		Expect(get().StatusCode).To(Equal(http.StatusOK))
+	})
+})
+
+var _ = Describe("ClientCertificate", func() {
+	var dir string
+	var certificate *webhook.ClientCertificate
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "client-certificate")
+		Expect(err).To(BeNil())
+		certificate = &webhook.ClientCertificate{
+			CertFile: filepath.Join(dir, "cert.pem"),
+			KeyFile:  filepath.Join(dir, "key.pem"),
+		}
+		writeTestCertificate(certificate.CertFile, certificate.KeyFile)
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+	It("reloads changed certificates", func() {
+		changed, err := certificate.Load()
+		Expect(err).To(BeNil())
+		Expect(changed).To(BeTrue())
+		first, err := certificate.GetClientCertificate(nil)
+		Expect(err).To(BeNil())
+
+		changed, err = certificate.Load()
+		Expect(err).To(BeNil())
+		Expect(changed).To(BeFalse())
+
+		writeTestCertificate(certificate.CertFile, certificate.KeyFile)
+		changed, err = certificate.Load()
+		Expect(err).To(BeNil())
+		Expect(changed).To(BeTrue())
+		second, err := certificate.GetClientCertificate(nil)
+		Expect(err).To(BeNil())
+		Expect(second.Certificate[0]).NotTo(Equal(first.Certificate[0]))
+	})
+	It("keeps the certificate if the files are invalid", func() {
+		_, err := certificate.Load()
+		Expect(err).To(BeNil())
+		Expect(ioutil.WriteFile(certificate.KeyFile, []byte("invalid"), 0600)).To(BeNil())
+		_, err = certificate.Load()
+		Expect(err).NotTo(BeNil())
+		_, err = certificate.GetClientCertificate(nil)
+		Expect(err).To(BeNil())
+	})
+})
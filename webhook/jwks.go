@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// JWKSPath is the path the public keys are published at.
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS is a set of JSON Web Keys.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewEd25519JWK returns the JWK of the given Ed25519 public key (RFC 8037).
+func NewEd25519JWK(keyID string, publicKey ed25519.PublicKey) JWK {
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		Kid: keyID,
+		Alg: "EdDSA",
+		Use: "sig",
+	}
+}
+
+// Add appends the key. A key id already published with the same key is skipped,
+// with a different key it is an error, because receivers could not tell the keys apart.
+func (j *JWKS) Add(jwk JWK) error {
+	for _, existing := range j.Keys {
+		if existing.Kid != jwk.Kid {
+			continue
+		}
+		if existing == jwk {
+			return nil
+		}
+		return errors.Errorf("key id %s used for different keys", jwk.Kid)
+	}
+	j.Keys = append(j.Keys, jwk)
+	return nil
+}
+
+// Ed25519Thumbprint returns the JWK thumbprint (RFC 7638) of the public key, usable as key id.
+func Ed25519Thumbprint(publicKey ed25519.PublicKey) string {
+	hash := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + base64.RawURLEncoding.EncodeToString(publicKey) + `"}`))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// ParseJWKS returns all Ed25519 public keys of the JWKS by key id.
+func ParseJWKS(content []byte) (map[string]ed25519.PublicKey, error) {
+	var jwks JWKS
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, errors.Wrap(err, "unmarshal jwks failed")
+	}
+	result := make(map[string]ed25519.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+			continue
+		}
+		publicKey, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode key %s failed", jwk.Kid)
+		}
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("invalid size of key %s", jwk.Kid)
+		}
+		result[jwk.Kid] = ed25519.PublicKey(publicKey)
+	}
+	return result, nil
+}
+
+// ServeHTTP publishes the keys.
+func (j *JWKS) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(resp).Encode(j); err != nil {
+		glog.Warningf("encode jwks failed: %v", err)
+	}
+}
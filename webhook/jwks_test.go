@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JWKS", func() {
+	var publicKey ed25519.PublicKey
+	BeforeEach(func() {
+		// test vector of RFC 8037 appendix A
+		key, err := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+		Expect(err).To(BeNil())
+		publicKey = ed25519.PublicKey(key)
+	})
+	It("returns jwk of public key", func() {
+		jwk := webhook.NewEd25519JWK("k1", publicKey)
+		Expect(jwk).To(Equal(webhook.JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+			Kid: "k1",
+			Alg: "EdDSA",
+			Use: "sig",
+		}))
+	})
+	It("returns thumbprint of public key", func() {
+		Expect(webhook.Ed25519Thumbprint(publicKey)).To(Equal("kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"))
+	})
+	It("publishes keys and parses them", func() {
+		jwks := &webhook.JWKS{
+			Keys: []webhook.JWK{webhook.NewEd25519JWK("k1", publicKey)},
+		}
+		recorder := httptest.NewRecorder()
+		jwks.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, webhook.JWKSPath, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		keys, err := webhook.ParseJWKS(recorder.Body.Bytes())
+		Expect(err).To(BeNil())
+		Expect(keys).To(Equal(map[string]ed25519.PublicKey{"k1": publicKey}))
+	})
+	It("adds a key once", func() {
+		jwks := &webhook.JWKS{}
+		Expect(jwks.Add(webhook.NewEd25519JWK("k1", publicKey))).To(BeNil())
+		Expect(jwks.Add(webhook.NewEd25519JWK("k1", publicKey))).To(BeNil())
+		Expect(jwks.Add(webhook.NewEd25519JWK("k2", publicKey))).To(BeNil())
+		Expect(jwks.Keys).To(HaveLen(2))
+	})
+	It("returns error if a key id is added with a different key", func() {
+		otherKey, _, err := ed25519.GenerateKey(nil)
+		Expect(err).To(BeNil())
+		jwks := &webhook.JWKS{}
+		Expect(jwks.Add(webhook.NewEd25519JWK("k1", publicKey))).To(BeNil())
+		Expect(jwks.Add(webhook.NewEd25519JWK("k1", otherKey))).NotTo(BeNil())
+		Expect(jwks.Keys).To(HaveLen(1))
+	})
+	It("ignores keys of other types", func() {
+		keys, err := webhook.ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"r1","n":"abc","e":"AQAB"}]}`))
+		Expect(err).To(BeNil())
+		Expect(keys).To(BeEmpty())
+	})
+})
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// SASL mechanisms
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
+)
+
+// KafkaSecurity configures TLS and SASL of the connections to the kafka brokers.
+type KafkaSecurity struct {
+	// TLS enables TLS, implied by TLSCA, TLSCert, TLSServerName or TLSInsecureSkipVerify
+	TLS bool
+	// TLSCA is a pem file with the CA bundle to verify brokers, system roots if empty
+	TLSCA string
+	// TLSCert and TLSKey are pem files of the client certificate
+	TLSCert string
+	TLSKey  string
+	// TLSServerName overrides the host name verified in broker certificates
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+	// SASLMechanism enables SASL if set
+	SASLMechanism string
+	SASLUser      string
+	SASLPassword  string
+}
+
+// Validate returns all problems of the settings at once.
+func (k *KafkaSecurity) Validate() []error {
+	var errs []error
+	if (k.TLSCert == "") != (k.TLSKey == "") {
+		errs = append(errs, errors.New("TLSCert and TLSKey must be given together"))
+	}
+	switch strings.ToUpper(k.SASLMechanism) {
+	case "":
+	case SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512:
+		if k.SASLUser == "" {
+			errs = append(errs, errors.New("SASLUser missing"))
+		}
+		if k.SASLPassword == "" {
+			errs = append(errs, errors.New("SASLPassword missing"))
+		}
+	default:
+		errs = append(errs, errors.Errorf("SASLMechanism %s unknown", k.SASLMechanism))
+	}
+	return errs
+}
+
+// Apply sets the TLS and SASL settings of the given config. Files are read once.
+func (k *KafkaSecurity) Apply(config *sarama.Config) error {
+	if k == nil {
+		return nil
+	}
+	if errs := k.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+	if k.tlsEnabled() {
+		tlsConfig, err := k.tlsConfig()
+		if err != nil {
+			return err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+	if k.SASLMechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.Handshake = true
+		config.Net.SASL.Mechanism = sarama.SASLMechanism(strings.ToUpper(k.SASLMechanism))
+		config.Net.SASL.User = k.SASLUser
+		config.Net.SASL.Password = k.SASLPassword
+		switch config.Net.SASL.Mechanism {
+		case SASLMechanismScramSHA256:
+			config.Net.SASL.SCRAMClientGeneratorFunc = newScramClientGenerator(sha256.New)
+		case SASLMechanismScramSHA512:
+			config.Net.SASL.SCRAMClientGeneratorFunc = newScramClientGenerator(sha512.New)
+		}
+	}
+	return nil
+}
+
+func (k *KafkaSecurity) tlsEnabled() bool {
+	return k.TLS || k.TLSCA != "" || k.TLSCert != "" || k.TLSServerName != "" || k.TLSInsecureSkipVerify
+}
+
+func (k *KafkaSecurity) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         k.TLSServerName,
+		InsecureSkipVerify: k.TLSInsecureSkipVerify,
+	}
+	if k.TLSCA != "" {
+		content, err := ioutil.ReadFile(k.TLSCA)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read ca %s failed", k.TLSCA)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, errors.Errorf("ca %s contains no certificate", k.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if k.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(k.TLSCert, k.TLSKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load client certificate %s failed", k.TLSCert)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/xdg/scram"
+)
+
+var _ = Describe("KafkaSecurity", func() {
+	var security *webhook.KafkaSecurity
+	var config *sarama.Config
+	BeforeEach(func() {
+		security = &webhook.KafkaSecurity{}
+		config = sarama.NewConfig()
+	})
+	It("keeps plaintext without settings", func() {
+		Expect(security.Apply(config)).To(BeNil())
+		Expect(config.Net.TLS.Enable).To(BeFalse())
+		Expect(config.Net.SASL.Enable).To(BeFalse())
+	})
+	It("ignores nil", func() {
+		security = nil
+		Expect(security.Apply(config)).To(BeNil())
+	})
+	It("enables sasl plain", func() {
+		security.SASLMechanism = webhook.SASLMechanismPlain
+		security.SASLUser = "user"
+		security.SASLPassword = "password"
+		Expect(security.Apply(config)).To(BeNil())
+		Expect(config.Net.SASL.Enable).To(BeTrue())
+		Expect(config.Net.SASL.User).To(Equal("user"))
+		Expect(config.Net.SASL.Password).To(Equal("password"))
+		Expect(config.Validate()).To(BeNil())
+	})
+	It("accepts lowercase mechanisms", func() {
+		security.SASLMechanism = "plain"
+		security.SASLUser = "user"
+		security.SASLPassword = "password"
+		Expect(security.Apply(config)).To(BeNil())
+		Expect(config.Net.SASL.Mechanism).To(Equal(sarama.SASLMechanism(sarama.SASLTypePlaintext)))
+	})
+	It("enables sasl scram", func() {
+		for mechanism, hashGeneratorFcn := range map[string]scram.HashGeneratorFcn{
+			webhook.SASLMechanismScramSHA256: sha256.New,
+			webhook.SASLMechanismScramSHA512: sha512.New,
+		} {
+			config = sarama.NewConfig()
+			security.SASLMechanism = mechanism
+			security.SASLUser = "user"
+			security.SASLPassword = "password"
+			Expect(security.Apply(config)).To(BeNil())
+			Expect(config.Net.SASL.Enable).To(BeTrue())
+			Expect(config.Net.SASL.Mechanism).To(Equal(sarama.SASLMechanism(mechanism)))
+			Expect(config.Validate()).To(BeNil())
+
+			client := config.Net.SASL.SCRAMClientGeneratorFunc()
+			Expect(client.Begin("user", "password", "")).To(BeNil())
+			server := newScramServer(hashGeneratorFcn, "user", "password")
+			var challenge string
+			for !client.Done() {
+				response, err := client.Step(challenge)
+				Expect(err).To(BeNil(), mechanism)
+				if response == "" {
+					continue
+				}
+				challenge, err = server.Step(response)
+				Expect(err).To(BeNil(), mechanism)
+			}
+			Expect(server.Valid()).To(BeTrue(), mechanism)
+		}
+	})
+	It("returns error for scram without password", func() {
+		security.SASLMechanism = webhook.SASLMechanismScramSHA512
+		security.SASLUser = "user"
+		Expect(security.Validate()).To(HaveLen(1))
+		Expect(security.Apply(config)).NotTo(BeNil())
+	})
+	It("returns error for unknown mechanism", func() {
+		security.SASLMechanism = "GSSAPI"
+		Expect(security.Validate()).To(HaveLen(1))
+	})
+	It("returns error for cert without key", func() {
+		security.TLSCert = "/cert.pem"
+		Expect(security.Validate()).To(HaveLen(1))
+	})
+	It("enables tls with server name", func() {
+		security.TLSServerName = "kafka.example.com"
+		Expect(security.Apply(config)).To(BeNil())
+		Expect(config.Net.TLS.Enable).To(BeTrue())
+		Expect(config.Net.TLS.Config.ServerName).To(Equal("kafka.example.com"))
+		Expect(config.Net.TLS.Config.RootCAs).To(BeNil())
+	})
+	Context("with files", func() {
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "tls")
+			Expect(err).To(BeNil())
+			writeTestCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+		It("loads ca and client certificate", func() {
+			security.TLSCA = filepath.Join(dir, "cert.pem")
+			security.TLSCert = filepath.Join(dir, "cert.pem")
+			security.TLSKey = filepath.Join(dir, "key.pem")
+			Expect(security.Apply(config)).To(BeNil())
+			Expect(config.Net.TLS.Enable).To(BeTrue())
+			Expect(config.Net.TLS.Config.RootCAs).NotTo(BeNil())
+			Expect(config.Net.TLS.Config.Certificates).To(HaveLen(1))
+		})
+		It("returns error if ca contains no certificate", func() {
+			security.TLSCA = filepath.Join(dir, "key.pem")
+			Expect(security.Apply(config)).NotTo(BeNil())
+		})
+		It("returns error if ca not exists", func() {
+			security.TLSCA = filepath.Join(dir, "missing.pem")
+			Expect(security.Apply(config)).NotTo(BeNil())
+		})
+	})
+})
+
+// newScramServer returns the server side of a SCRAM conversation knowing one user.
+func newScramServer(hashGeneratorFcn scram.HashGeneratorFcn, user string, password string) *scram.ServerConversation {
+	client, err := hashGeneratorFcn.NewClient(user, password, "")
+	Expect(err).To(BeNil())
+	credentials := client.GetStoredCredentials(scram.KeyFactors{Salt: "salt", Iters: 4096})
+	server, err := hashGeneratorFcn.NewServer(func(name string) (scram.StoredCredentials, error) {
+		if name != user {
+			return scram.StoredCredentials{}, errors.New("unknown user")
+		}
+		return credentials, nil
+	})
+	Expect(err).To(BeNil())
+	return server.NewConversation()
+}
+
+// writeTestCertificate writes a self signed certificate and its key.
+func writeTestCertificate(certPath string, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).To(BeNil())
+	Expect(ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(BeNil())
+	Expect(ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).To(BeNil())
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// PrimaryKeyFile is the file of a key directory that contains the id of the primary key.
+const PrimaryKeyFile = "primary"
+
+// Key is a secret identified by its ID.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// KeySet signs with the primary key and accepts signatures of all keys.
+// Signatures are formatted as "<key id>=<hex>", multiple signatures are separated by comma.
+type KeySet struct {
+	mux     sync.RWMutex
+	primary Key
+	keys    []Key
+}
+
+// NewKeySet returns a KeySet with the given primary key id and keys.
+func NewKeySet(primary string, keys []Key) (*KeySet, error) {
+	keySet := &KeySet{}
+	if err := keySet.Update(primary, keys); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+// Update replaces all keys. The primary key must be part of keys.
+func (k *KeySet) Update(primary string, keys []Key) error {
+	var primaryKey *Key
+	ids := make(map[string]bool)
+	for i, key := range keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, "=, ") {
+			return errors.Errorf("invalid key id %q", key.ID)
+		}
+		if key.Secret == "" {
+			return errors.Errorf("secret of key %s missing", key.ID)
+		}
+		if ids[key.ID] {
+			return errors.Errorf("key id %s is not unique", key.ID)
+		}
+		ids[key.ID] = true
+		if key.ID == primary {
+			primaryKey = &keys[i]
+		}
+	}
+	if primaryKey == nil {
+		return errors.Errorf("primary key %s not found", primary)
+	}
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.primary = *primaryKey
+	k.keys = append([]Key(nil), keys...)
+	return nil
+}
+
+// Sign returns the signature of the primary key.
+func (k *KeySet) Sign(content []byte) string {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return k.primary.ID + "=" + hex.EncodeToString(hmacSha256(k.primary.Secret, content))
+}
+
+// Compare returns true if any of the given signatures matches one of the keys.
+// Signatures without key id are compared with all keys.
+func (k *KeySet) Compare(content []byte, sign string) (bool, error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	parsed := 0
+	for _, part := range splitList(sign) {
+		id, value := "", part
+		if pos := strings.Index(part, "="); pos != -1 {
+			id, value = part[:pos], part[pos+1:]
+		}
+		mac, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		parsed++
+		for _, key := range k.keys {
+			if id != "" && id != key.ID {
+				continue
+			}
+			if hmac.Equal(hmacSha256(key.Secret, content), mac) {
+				return true, nil
+			}
+		}
+	}
+	if parsed == 0 {
+		return false, errors.New("no valid signature found")
+	}
+	return false, nil
+}
+
+// ReadKeys reads keys from a directory or a YAML file.
+//
+// A directory, like a mounted Kubernetes Secret, contains one file per key named by the key id
+// and the file "primary" with the id of the primary key.
+//
+// A YAML file looks like:
+//
+//	primary: k2
+//	keys:
+//	  k1: secret1
+//	  k2: secret2
+func ReadKeys(path string) (string, []Key, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "stat %s failed", path)
+	}
+	if info.IsDir() {
+		return readKeyDirectory(path)
+	}
+	return readKeyFile(path)
+}
+
+func readKeyDirectory(path string) (string, []Key, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "read directory %s failed", path)
+	}
+	var primary string
+	var keys []Key
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		// follow symlinks created by kubernetes secret mounts
+		info, err := os.Stat(filepath.Join(path, name))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "read key %s failed", name)
+		}
+		value := strings.TrimSpace(string(content))
+		if name == PrimaryKeyFile {
+			primary = value
+			continue
+		}
+		keys = append(keys, Key{ID: name, Secret: value})
+	}
+	if primary == "" && len(keys) == 1 {
+		primary = keys[0].ID
+	}
+	return primary, keys, nil
+}
+
+func readKeyFile(path string) (string, []Key, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "read file %s failed", path)
+	}
+	var data struct {
+		Primary string            `yaml:"primary"`
+		Keys    map[string]string `yaml:"keys"`
+	}
+	if err := yaml.UnmarshalStrict(content, &data); err != nil {
+		return "", nil, errors.Wrapf(err, "parse file %s failed", path)
+	}
+	ids := make([]string, 0, len(data.Keys))
+	for id := range data.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	keys := make([]Key, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, Key{ID: id, Secret: data.Keys[id]})
+	}
+	return data.Primary, keys, nil
+}
+
+// KeySetLoader keeps a KeySet in sync with the keys of Path.
+type KeySetLoader struct {
+	Path     string
+	KeySet   *KeySet
+	Interval time.Duration
+}
+
+// Load reads the keys and updates the KeySet.
+func (k *KeySetLoader) Load() error {
+	primary, keys, err := ReadKeys(k.Path)
+	if err != nil {
+		return err
+	}
+	if err := k.KeySet.Update(primary, keys); err != nil {
+		return errors.Wrapf(err, "update keys of %s failed", k.Path)
+	}
+	return nil
+}
+
+// Run reloads the keys every Interval until the context is done. Invalid keys are logged and ignored.
+func (k *KeySetLoader) Run(ctx context.Context) error {
+	if k.Interval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			primary, keys, err := ReadKeys(k.Path)
+			if err != nil {
+				glog.Warningf("read keys failed: %v", err)
+				continue
+			}
+			if k.unchanged(primary, keys) {
+				continue
+			}
+			if err := k.KeySet.Update(primary, keys); err != nil {
+				glog.Warningf("update keys of %s failed: %v", k.Path, err)
+				continue
+			}
+			glog.V(0).Infof("keys of %s reloaded, primary key is %s", k.Path, primary)
+		}
+	}
+}
+
+func (k *KeySetLoader) unchanged(primary string, keys []Key) bool {
+	k.KeySet.mux.RLock()
+	defer k.KeySet.mux.RUnlock()
+	return k.KeySet.primary.ID == primary && reflect.DeepEqual(k.KeySet.keys, keys)
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeySet", func() {
+	var keySet *webhook.KeySet
+	content := []byte("banana")
+	BeforeEach(func() {
+		var err error
+		keySet, err = webhook.NewKeySet("k2", []webhook.Key{
+			{ID: "k1", Secret: "secret1"},
+			{ID: "k2", Secret: "secret2"},
+		})
+		Expect(err).To(BeNil())
+	})
+	It("signs with primary key", func() {
+		signer := &webhook.Signer{Secret: "secret2"}
+		Expect(keySet.Sign(content)).To(Equal("k2=" + signer.Sign(content)))
+	})
+	It("accepts signatures of all keys", func() {
+		signer := &webhook.Signer{Secret: "secret1"}
+		equal, err := keySet.Compare(content, "k1="+signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeTrue())
+	})
+	It("accepts signatures without key id", func() {
+		signer := &webhook.Signer{Secret: "secret1"}
+		equal, err := keySet.Compare(content, signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeTrue())
+	})
+	It("accepts any matching signature of a list", func() {
+		signer := &webhook.Signer{Secret: "secret1"}
+		equal, err := keySet.Compare(content, "k3=abcd,k1="+signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeTrue())
+	})
+	It("rejects signatures with wrong key id", func() {
+		signer := &webhook.Signer{Secret: "secret1"}
+		equal, err := keySet.Compare(content, "k2="+signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeFalse())
+	})
+	It("rejects signatures of unknown keys", func() {
+		signer := &webhook.Signer{Secret: "other"}
+		equal, err := keySet.Compare(content, signer.Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeFalse())
+	})
+	It("returns error for invalid signatures", func() {
+		_, err := keySet.Compare(content, "k1=xyz")
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns error if primary key is unknown", func() {
+		_, err := webhook.NewKeySet("k3", []webhook.Key{{ID: "k1", Secret: "secret1"}})
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns error for invalid key id", func() {
+		_, err := webhook.NewKeySet("k=1", []webhook.Key{{ID: "k=1", Secret: "secret1"}})
+		Expect(err).To(HaveOccurred())
+	})
+	It("rotates keys", func() {
+		Expect(keySet.Update("k3", []webhook.Key{
+			{ID: "k2", Secret: "secret2"},
+			{ID: "k3", Secret: "secret3"},
+		})).To(BeNil())
+		Expect(keySet.Sign(content)).To(HavePrefix("k3="))
+		equal, err := keySet.Compare(content, (&webhook.Signer{Secret: "secret1"}).Sign(content))
+		Expect(err).To(BeNil())
+		Expect(equal).To(BeFalse())
+	})
+	Context("loader", func() {
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "keys")
+			Expect(err).To(BeNil())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+		writeFile := func(name string, content string) {
+			Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(BeNil())
+		}
+		It("reads keys of directory", func() {
+			writeFile("k1", "secret1\n")
+			writeFile("k2", "secret2\n")
+			writeFile("primary", "k2\n")
+			writeFile("..data", "ignored")
+			primary, keys, err := webhook.ReadKeys(dir)
+			Expect(err).To(BeNil())
+			Expect(primary).To(Equal("k2"))
+			Expect(keys).To(Equal([]webhook.Key{
+				{ID: "k1", Secret: "secret1"},
+				{ID: "k2", Secret: "secret2"},
+			}))
+		})
+		It("uses single key of directory as primary", func() {
+			writeFile("k1", "secret1")
+			primary, _, err := webhook.ReadKeys(dir)
+			Expect(err).To(BeNil())
+			Expect(primary).To(Equal("k1"))
+		})
+		It("reads keys of yaml file", func() {
+			writeFile("keys.yaml", "primary: k1\nkeys:\n  k2: secret2\n  k1: secret1\n")
+			primary, keys, err := webhook.ReadKeys(filepath.Join(dir, "keys.yaml"))
+			Expect(err).To(BeNil())
+			Expect(primary).To(Equal("k1"))
+			Expect(keys).To(Equal([]webhook.Key{
+				{ID: "k1", Secret: "secret1"},
+				{ID: "k2", Secret: "secret2"},
+			}))
+		})
+		It("reloads changed keys", func() {
+			writeFile("k1", "secret1")
+			loader := &webhook.KeySetLoader{
+				Path:     dir,
+				KeySet:   &webhook.KeySet{},
+				Interval: time.Millisecond,
+			}
+			Expect(loader.Load()).To(BeNil())
+			Expect(loader.KeySet.Sign(content)).To(HavePrefix("k1="))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				defer GinkgoRecover()
+				Expect(loader.Run(ctx)).To(BeNil())
+			}()
+			writeFile("k2", "secret2")
+			writeFile("primary", "k2")
+			Eventually(func() string {
+				return loader.KeySet.Sign(content)
+			}).Should(HavePrefix("k2="))
+		})
+		It("keeps keys if reload fails", func() {
+			writeFile("k1", "secret1")
+			loader := &webhook.KeySetLoader{
+				Path:     dir,
+				KeySet:   &webhook.KeySet{},
+				Interval: time.Millisecond,
+			}
+			Expect(loader.Load()).To(BeNil())
+			writeFile("primary", "unknown")
+			Expect(loader.Load()).To(HaveOccurred())
+			Expect(strings.HasPrefix(loader.KeySet.Sign(content), "k1=")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	filteredMessagesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "filtered_messages_total",
+		Help:      "messages not delivered because they did not match the filter",
+	}, []string{"route", "topic"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		filteredMessagesCounter,
+	)
+}
+
+//go:generate counterfeiter -o ../mocks/message_filter.go --fake-name MessageFilter . MessageFilter
+type MessageFilter interface {
+	// Match returns true if the message is delivered
+	Match(msg *sarama.ConsumerMessage) bool
+}
+
+// RouteFilter matches messages against the Filter of a route. Other messages are counted as filtered.
+type RouteFilter struct {
+	Route  string
+	Filter *Filter
+}
+
+func (r *RouteFilter) Match(msg *sarama.ConsumerMessage) bool {
+	if r.Filter.Match(msg) {
+		return true
+	}
+	filteredMessagesCounter.WithLabelValues(r.Route, msg.Topic).Inc()
+	glog.V(3).Infof("message %d of topic %s partition %d filtered by route %s", msg.Offset, msg.Topic, msg.Partition, r.Route)
+	return false
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ = Describe("RouteFilter", func() {
+	var filter *webhook.RouteFilter
+	filtered := func(route string) float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		Expect(err).To(BeNil())
+		for _, family := range families {
+			if family.GetName() != "webhook_filtered_messages_total" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "route" && label.GetValue() == route {
+						return metric.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return 0
+	}
+	BeforeEach(func() {
+		expression, err := webhook.ParseFilter(`key == "a"`)
+		Expect(err).To(BeNil())
+		filter = &webhook.RouteFilter{
+			Route:  "filter-test",
+			Filter: expression,
+		}
+	})
+	It("matches messages matching the filter", func() {
+		before := filtered("filter-test")
+		Expect(filter.Match(&sarama.ConsumerMessage{Topic: "orders", Key: []byte("a")})).To(BeTrue())
+		Expect(filtered("filter-test")).To(Equal(before))
+	})
+	It("counts other messages as filtered of the route", func() {
+		before := filtered("filter-test")
+		Expect(filter.Match(&sarama.ConsumerMessage{Topic: "orders", Key: []byte("b")})).To(BeFalse())
+		Expect(filtered("filter-test")).To(Equal(before + 1))
+	})
+})
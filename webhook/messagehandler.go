@@ -14,3 +14,17 @@ import (
 type MessageHandler interface {
 	ConsumeMessage(ctx context.Context, msg *sarama.ConsumerMessage) error
 }
+
+//go:generate counterfeiter -o ../mocks/batch_messagehandler.go --fake-name BatchMessageHandler . BatchMessageHandler
+
+// BatchMessageHandler handles multiple messages of one partition at once.
+type BatchMessageHandler interface {
+	ConsumeMessages(ctx context.Context, msgs []*sarama.ConsumerMessage) error
+}
+
+//go:generate counterfeiter -o ../mocks/processor.go --fake-name Processor . Processor
+
+// Processor delivers the messages of one partition and calls mark for the last message of every delivered prefix.
+type Processor interface {
+	Process(ctx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(msg *sarama.ConsumerMessage)) error
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deliveredMessagesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivered_messages_total",
+		Help:      "messages delivered to the webhook",
+	}, []string{"route"})
+	failedMessagesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_messages_total",
+		Help:      "messages not delivered after all retries",
+	}, []string{"route"})
+	deliveryDurationSummary = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace: namespace,
+		Name:      "delivery_duration_seconds",
+		Help:      "duration of deliveries including retries",
+	}, []string{"route"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		deliveredMessagesCounter,
+		failedMessagesCounter,
+		deliveryDurationSummary,
+	)
+}
+
+// MetricsMessageHandler counts the delivered and failed messages of a route.
+type MetricsMessageHandler struct {
+	Route          string
+	MessageHandler MessageHandler
+	BatchHandler   BatchMessageHandler
+}
+
+func (m *MetricsMessageHandler) ConsumeMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	start := time.Now()
+	err := m.MessageHandler.ConsumeMessage(ctx, msg)
+	m.observe(ctx, start, 1, err)
+	return err
+}
+
+func (m *MetricsMessageHandler) ConsumeMessages(ctx context.Context, msgs []*sarama.ConsumerMessage) error {
+	start := time.Now()
+	err := m.BatchHandler.ConsumeMessages(ctx, msgs)
+	m.observe(ctx, start, len(msgs), err)
+	return err
+}
+
+// observe counts nothing if ctx is done, the messages are left to the next owner.
+func (m *MetricsMessageHandler) observe(ctx context.Context, start time.Time, count int, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	deliveryDurationSummary.WithLabelValues(m.Route).Observe(time.Since(start).Seconds())
+	if err != nil {
+		failedMessagesCounter.WithLabelValues(m.Route).Add(float64(count))
+		return
+	}
+	deliveredMessagesCounter.WithLabelValues(m.Route).Add(float64(count))
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+//go:generate counterfeiter -o ../mocks/offset_committer.go --fake-name OffsetCommitter . OffsetCommitter
+
+// OffsetCommitter commits offsets of a group without joining it.
+type OffsetCommitter interface {
+	// CommitOffsets commits the offsets of the partitions of the topic, also if they move backward
+	CommitOffsets(group string, topic string, offsets map[int32]int64) error
+}
+
+// OffsetManagerCommitter commits offsets with a sarama offset manager.
+type OffsetManagerCommitter struct {
+	Client sarama.Client
+}
+
+func (o *OffsetManagerCommitter) CommitOffsets(group string, topic string, offsets map[int32]int64) error {
+	offsetManager, err := sarama.NewOffsetManagerFromClient(group, o.Client)
+	if err != nil {
+		return errors.Wrapf(err, "create offset manager of group %s failed", group)
+	}
+	var partitionOffsetManagers []sarama.PartitionOffsetManager
+	for partition, offset := range offsets {
+		partitionOffsetManager, err := offsetManager.ManagePartition(topic, partition)
+		if err != nil {
+			_ = offsetManager.Close()
+			return errors.Wrapf(err, "manage topic %s partition %d failed", topic, partition)
+		}
+		// ResetOffset only moves backward and MarkOffset only forward
+		partitionOffsetManager.ResetOffset(offset, "")
+		partitionOffsetManager.MarkOffset(offset, "")
+		partitionOffsetManagers = append(partitionOffsetManagers, partitionOffsetManager)
+	}
+	// close flushes the offsets
+	if err := offsetManager.Close(); err != nil {
+		return errors.Wrapf(err, "commit offsets of group %s failed", group)
+	}
+	// errors of failed attempts are returned even if a retry succeeded, the caller reads the offsets back
+	for _, partitionOffsetManager := range partitionOffsetManagers {
+		if err := partitionOffsetManager.Close(); err != nil {
+			glog.Warningf("commit offsets of topic %s of group %s: %v", topic, group, err)
+		}
+	}
+	return nil
+}
+
+// OffsetReset moves the committed offsets of a group to the start position.
+// The offsets are committed outside of a group generation, kafka only accepts them while the group has no members.
+type OffsetReset struct {
+	KafkaGroup    string
+	StartPosition *StartPosition
+	// Offsets resolves the start position and reads the offsets back
+	Offsets   OffsetSource
+	Committer OffsetCommitter
+}
+
+// Reset commits the start position of all given partitions by topic and verifies the committed offsets.
+func (o *OffsetReset) Reset(topics map[string][]int32) error {
+	names := make([]string, 0, len(topics))
+	for topic := range topics {
+		names = append(names, topic)
+	}
+	sort.Strings(names)
+	for _, topic := range names {
+		partitions := topics[topic]
+		offsets := make(map[int32]int64, len(partitions))
+		for _, partition := range partitions {
+			offset, err := o.StartPosition.Resolve(topic, partition, o.Offsets.GetOffset)
+			if err != nil {
+				return err
+			}
+			offsets[partition] = offset
+		}
+		if err := o.Committer.CommitOffsets(o.KafkaGroup, topic, offsets); err != nil {
+			return err
+		}
+		committed, err := o.Offsets.CommittedOffsets(o.KafkaGroup, topic, partitions)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			if committed[partition] != offsets[partition] {
+				return errors.Errorf("reset topic %s partition %d of group %s to offset %d failed, committed is %d, is the group still running?", topic, partition, o.KafkaGroup, offsets[partition], committed[partition])
+			}
+			glog.V(0).Infof("reset topic %s partition %d of group %s to offset %d", topic, partition, o.KafkaGroup, offsets[partition])
+		}
+	}
+	return nil
+}
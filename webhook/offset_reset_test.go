@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"errors"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OffsetReset", func() {
+	var offsetReset *webhook.OffsetReset
+	var offsetSource *mocks.OffsetSource
+	var committer *mocks.OffsetCommitter
+	BeforeEach(func() {
+		offsetSource = &mocks.OffsetSource{}
+		offsetSource.GetOffsetStub = func(topic string, partition int32, time int64) (int64, error) {
+			return 100 + int64(partition), nil
+		}
+		offsetSource.CommittedOffsetsReturns(map[int32]int64{0: 100, 1: 101}, nil)
+		committer = &mocks.OffsetCommitter{}
+		offsetReset = &webhook.OffsetReset{
+			KafkaGroup:    "group",
+			StartPosition: &webhook.StartPosition{Initial: sarama.OffsetNewest},
+			Offsets:       offsetSource,
+			Committer:     committer,
+		}
+	})
+	It("commits the start position of all partitions", func() {
+		Expect(offsetReset.Reset(map[string][]int32{"topic": {0, 1}})).To(BeNil())
+		Expect(committer.CommitOffsetsCallCount()).To(Equal(1))
+		group, topic, offsets := committer.CommitOffsetsArgsForCall(0)
+		Expect(group).To(Equal("group"))
+		Expect(topic).To(Equal("topic"))
+		Expect(offsets).To(Equal(map[int32]int64{0: 100, 1: 101}))
+	})
+	It("commits explicit offsets", func() {
+		offsetReset.StartPosition = &webhook.StartPosition{Initial: sarama.OffsetOldest, Offsets: map[int32]int64{0: 100, 1: 101}}
+		Expect(offsetReset.Reset(map[string][]int32{"topic": {0, 1}})).To(BeNil())
+		Expect(offsetSource.GetOffsetCallCount()).To(Equal(0))
+	})
+	It("returns error if the offsets were not committed", func() {
+		offsetSource.CommittedOffsetsReturns(map[int32]int64{0: 100, 1: 7}, nil)
+		Expect(offsetReset.Reset(map[string][]int32{"topic": {0, 1}})).NotTo(BeNil())
+	})
+	It("returns error if commit fails", func() {
+		committer.CommitOffsetsReturns(errors.New("banana"))
+		Expect(offsetReset.Reset(map[string][]int32{"topic": {0, 1}})).NotTo(BeNil())
+		Expect(offsetSource.CommittedOffsetsCallCount()).To(Equal(0))
+	})
+})
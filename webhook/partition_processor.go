@@ -0,0 +1,338 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+)
+
+// DefaultStrictBackoff is the wait between attempts of a blocking message in strict mode if no Backoff is configured.
+var DefaultStrictBackoff Backoff = &ConstantBackoff{Delay: time.Second}
+
+// Defaults of batch delivery
+const (
+	DefaultBatchSize   = 100
+	DefaultBatchLinger = time.Second
+)
+
+// PartitionProcessor delivers the messages of one partition in order and marks the delivered ones.
+// With Workers messages of different keys are delivered concurrently, the order per key is kept.
+// With a BatchHandler messages are collected and delivered in batches.
+// Messages not matching the Filter are marked without delivery, they are filtered once before the first attempt.
+//
+// Default mode: a message failing in the MessageHandler is skipped and the partition continues.
+// Marking a later message commits past the failed one, so failed messages are lost unless the
+// MessageHandler routes them elsewhere, e.g. a DeadLetterMessageHandler.
+//
+// Strict mode guarantees no message is skipped: the offset of a message is marked only after the
+// MessageHandler returned nil for it and for every earlier message of the partition. A failing
+// message blocks the partition and is attempted again until it succeeds, the MessageHandler routes
+// it to a failure sink and returns nil, or the partition is revoked. Revoked partitions continue
+// at the blocking message on the next owner.
+type PartitionProcessor struct {
+	MessageHandler MessageHandler
+	// Filter drops messages before delivery, all messages are delivered if nil
+	Filter MessageFilter
+	// Strict blocks the partition on failing messages instead of skipping them
+	Strict bool
+	// Backoff calculates the wait between attempts of a blocking message, DefaultStrictBackoff if nil
+	Backoff Backoff
+	// Clock used to wait, SystemClock if nil
+	Clock Clock
+	// Workers is the amount of concurrent deliveries, messages with the same key are delivered in order. Sequential if less than two
+	Workers int
+	// BatchHandler delivers batches instead of single messages if set, replaces MessageHandler and Workers
+	BatchHandler BatchMessageHandler
+	// BatchSize is the maximum amount of messages per batch, DefaultBatchSize if zero
+	BatchSize int
+	// BatchBytes is the maximum size of keys and values per batch, unlimited if zero. A larger message is sent alone
+	BatchBytes int
+	// BatchLinger is the maximum time to wait for a batch to fill, DefaultBatchLinger if zero
+	BatchLinger time.Duration
+	// DrainTimeout is the time an in-flight delivery may take after ctx is done, DefaultDrainTimeout if zero
+	DrainTimeout time.Duration
+}
+
+// Process delivers messages until the channel is closed or ctx is done and calls mark for every delivered message.
+// The in-flight deliveries are drained when ctx is done, but their offsets are only marked if they finished within DrainTimeout.
+func (p *PartitionProcessor) Process(ctx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(msg *sarama.ConsumerMessage)) error {
+	deliveryCtx, cancel := drainContext(ctx, p.drainTimeout())
+	defer cancel()
+	if p.BatchHandler != nil {
+		return p.processBatches(ctx, deliveryCtx, messages, mark)
+	}
+	if p.Workers > 1 {
+		return p.processParallel(ctx, deliveryCtx, messages, mark)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok || ctx.Err() != nil {
+				return nil
+			}
+			if glog.V(4) {
+				glog.Infof("handle message: %s", string(msg.Value))
+			}
+			if !p.deliver(ctx, deliveryCtx, msg) {
+				continue
+			}
+			if deliveryCtx.Err() != nil {
+				glog.V(1).Infof("drain message %d of partition %d timed out, leave it to the next owner", msg.Offset, msg.Partition)
+				return nil
+			}
+			mark(msg)
+			glog.V(3).Infof("message %d consumed successful", msg.Offset)
+		}
+	}
+}
+
+// processParallel dispatches messages to Workers by key, so messages with the same key are delivered in order.
+// Messages without key are distributed round robin. Only the contiguous prefix of finished messages is marked.
+func (p *PartitionProcessor) processParallel(ctx context.Context, deliveryCtx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(msg *sarama.ConsumerMessage)) error {
+	watermark := &offsetWatermark{}
+	queues := make([]chan *sarama.ConsumerMessage, p.Workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *sarama.ConsumerMessage, 1)
+		wg.Add(1)
+		go func(queue <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			for msg := range queue {
+				if ctx.Err() != nil {
+					// revoked, queued messages are left to the next owner
+					continue
+				}
+				if glog.V(4) {
+					glog.Infof("handle message: %s", string(msg.Value))
+				}
+				delivered := p.deliver(ctx, deliveryCtx, msg)
+				if deliveryCtx.Err() != nil || !delivered && p.Strict {
+					continue
+				}
+				watermark.finish(msg, mark)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+	var next int
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok || ctx.Err() != nil {
+				return nil
+			}
+			var worker int
+			if len(msg.Key) == 0 {
+				worker = next % len(queues)
+				next++
+			} else {
+				hash := fnv.New32a()
+				hash.Write(msg.Key)
+				worker = int(hash.Sum32() % uint32(len(queues)))
+			}
+			watermark.add(msg)
+			select {
+			case <-ctx.Done():
+				return nil
+			case queues[worker] <- msg:
+			}
+		}
+	}
+}
+
+// processBatches collects messages until BatchSize, BatchBytes or BatchLinger is reached and delivers them at once.
+// The last message of a batch is marked after the whole batch was delivered.
+func (p *PartitionProcessor) processBatches(ctx context.Context, deliveryCtx context.Context, messages <-chan *sarama.ConsumerMessage, mark func(msg *sarama.ConsumerMessage)) error {
+	var batch []*sarama.ConsumerMessage
+	var size int
+	var linger <-chan time.Time
+	// flush returns false if the delivery was not finished within DrainTimeout
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		delivered := p.deliverBatch(ctx, deliveryCtx, batch)
+		if deliveryCtx.Err() != nil {
+			glog.V(1).Infof("drain batch of partition %d timed out, leave it to the next owner", batch[0].Partition)
+			return false
+		}
+		if delivered {
+			mark(batch[len(batch)-1])
+			glog.V(3).Infof("batch of %d messages until %d consumed successful", len(batch), batch[len(batch)-1].Offset)
+		}
+		batch, size, linger = nil, 0, nil
+		return true
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-linger:
+			if !flush() {
+				return nil
+			}
+		case msg, ok := <-messages:
+			if !ok || ctx.Err() != nil {
+				return nil
+			}
+			msgSize := len(msg.Key) + len(msg.Value)
+			if p.BatchBytes > 0 && len(batch) > 0 && size+msgSize > p.BatchBytes {
+				if !flush() {
+					return nil
+				}
+			}
+			if len(batch) == 0 {
+				linger = p.clock().After(p.batchLinger())
+			}
+			batch = append(batch, msg)
+			size += msgSize
+			if len(batch) >= p.batchSize() || p.BatchBytes > 0 && size >= p.BatchBytes {
+				if !flush() {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// deliver returns true if the message may be marked.
+func (p *PartitionProcessor) deliver(ctx context.Context, deliveryCtx context.Context, msg *sarama.ConsumerMessage) bool {
+	if !p.match(msg) {
+		return true
+	}
+	return p.attempt(ctx, fmt.Sprintf("message %d of partition %d", msg.Offset, msg.Partition), func() error {
+		return p.MessageHandler.ConsumeMessage(deliveryCtx, msg)
+	})
+}
+
+// deliverBatch returns true if the messages of the batch may be marked.
+// Messages not matching the Filter are removed from the batch, a batch without matching message is not delivered.
+func (p *PartitionProcessor) deliverBatch(ctx context.Context, deliveryCtx context.Context, batch []*sarama.ConsumerMessage) bool {
+	msgs := make([]*sarama.ConsumerMessage, 0, len(batch))
+	for _, msg := range batch {
+		if p.match(msg) {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) == 0 {
+		return true
+	}
+	first, last := msgs[0], msgs[len(msgs)-1]
+	return p.attempt(ctx, fmt.Sprintf("batch %d-%d of partition %d", first.Offset, last.Offset, first.Partition), func() error {
+		return p.BatchHandler.ConsumeMessages(deliveryCtx, msgs)
+	})
+}
+
+// attempt calls fn once or, in strict mode, until it succeeds or ctx is done.
+func (p *PartitionProcessor) attempt(ctx context.Context, name string, fn func() error) bool {
+	var wait time.Duration
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return true
+		}
+		if !p.Strict {
+			glog.V(1).Infof("consume %s failed: %v", name, err)
+			return false
+		}
+		wait = p.backoff().NextDelay(attempt, wait)
+		glog.Warningf("consume %s failed %d times, partition blocked, retry in %v: %v", name, attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-p.clock().After(wait):
+		}
+	}
+}
+
+func (p *PartitionProcessor) match(msg *sarama.ConsumerMessage) bool {
+	return p.Filter == nil || p.Filter.Match(msg)
+}
+
+func (p *PartitionProcessor) batchSize() int {
+	if p.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return p.BatchSize
+}
+
+func (p *PartitionProcessor) batchLinger() time.Duration {
+	if p.BatchLinger <= 0 {
+		return DefaultBatchLinger
+	}
+	return p.BatchLinger
+}
+
+func (p *PartitionProcessor) backoff() Backoff {
+	if p.Backoff == nil {
+		return DefaultStrictBackoff
+	}
+	return p.Backoff
+}
+
+func (p *PartitionProcessor) clock() Clock {
+	if p.Clock != nil {
+		return p.Clock
+	}
+	return SystemClock{}
+}
+
+func (p *PartitionProcessor) drainTimeout() time.Duration {
+	if p.DrainTimeout <= 0 {
+		return DefaultDrainTimeout
+	}
+	return p.DrainTimeout
+}
+
+// offsetWatermark tracks the messages of a partition in flight.
+// A message is marked after it and all earlier messages finished, so only fully delivered prefixes are committed.
+type offsetWatermark struct {
+	mux      sync.Mutex
+	pending  []*sarama.ConsumerMessage
+	finished map[int64]bool
+}
+
+// add registers a message before it is delivered. Messages must be added in offset order.
+func (o *offsetWatermark) add(msg *sarama.ConsumerMessage) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.pending = append(o.pending, msg)
+}
+
+// finish records the message as finished and marks the last message of the finished prefix.
+func (o *offsetWatermark) finish(msg *sarama.ConsumerMessage, mark func(msg *sarama.ConsumerMessage)) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.finished == nil {
+		o.finished = make(map[int64]bool)
+	}
+	o.finished[msg.Offset] = true
+	var last *sarama.ConsumerMessage
+	for len(o.pending) > 0 && o.finished[o.pending[0].Offset] {
+		last = o.pending[0]
+		delete(o.finished, last.Offset)
+		o.pending = o.pending[1:]
+	}
+	if last != nil {
+		mark(last)
+		glog.V(3).Infof("messages until %d consumed successful", last.Offset)
+	}
+}
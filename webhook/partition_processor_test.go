@@ -0,0 +1,305 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	saramamocks "github.com/Shopify/sarama/mocks"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PartitionProcessor", func() {
+	var processor *webhook.PartitionProcessor
+	var messageHandler *mocks.MessageHandler
+	var clock *mocks.Clock
+	var consumer *saramamocks.Consumer
+	var partitionConsumer sarama.PartitionConsumer
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var mux sync.Mutex
+	var marked []int64
+	mark := func(msg *sarama.ConsumerMessage) {
+		mux.Lock()
+		defer mux.Unlock()
+		marked = append(marked, msg.Offset)
+	}
+	markedOffsets := func() []int64 {
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]int64{}, marked...)
+	}
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		marked = nil
+		messageHandler = &mocks.MessageHandler{}
+		clock = &mocks.Clock{}
+		clock.AfterStub = func(d time.Duration) <-chan time.Time {
+			c := make(chan time.Time, 1)
+			c <- time.Now()
+			return c
+		}
+		processor = &webhook.PartitionProcessor{
+			MessageHandler: messageHandler,
+			Clock:          clock,
+			DrainTimeout:   time.Second,
+		}
+		consumer = saramamocks.NewConsumer(GinkgoT(), nil)
+		expectation := consumer.ExpectConsumePartition("orders", 0, sarama.OffsetOldest)
+		// the mock assigns the offsets 1, 2 and 3
+		for _, key := range []string{"a", "b", "b"} {
+			expectation.YieldMessage(&sarama.ConsumerMessage{Key: []byte(key)})
+		}
+		var err error
+		partitionConsumer, err = consumer.ConsumePartition("orders", 0, sarama.OffsetOldest)
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		cancel()
+		Expect(partitionConsumer.Close()).To(BeNil())
+		Expect(consumer.Close()).To(BeNil())
+	})
+	process := func() chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- processor.Process(ctx, partitionConsumer.Messages(), mark)
+		}()
+		return done
+	}
+	It("marks all delivered messages in order", func() {
+		done := process()
+		Eventually(markedOffsets).Should(Equal([]int64{1, 2, 3}))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+	It("skips failing messages by default", func() {
+		messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+			if msg.Offset == 2 {
+				return errors.New("banana")
+			}
+			return nil
+		}
+		done := process()
+		Eventually(markedOffsets).Should(Equal([]int64{1, 3}))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+	It("marks filtered messages without delivery", func() {
+		filter := &mocks.MessageFilter{}
+		filter.MatchStub = func(msg *sarama.ConsumerMessage) bool {
+			return string(msg.Key) == "a"
+		}
+		processor.Filter = filter
+		done := process()
+		Eventually(markedOffsets).Should(Equal([]int64{1, 2, 3}))
+		Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(1))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+	Context("strict", func() {
+		BeforeEach(func() {
+			processor.Strict = true
+		})
+		It("blocks the partition until the failing message succeeds", func() {
+			failures := 3
+			messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+				if msg.Offset == 2 && failures > 0 {
+					failures--
+					return errors.New("banana")
+				}
+				return nil
+			}
+			done := process()
+			Eventually(markedOffsets).Should(Equal([]int64{1, 2, 3}))
+			Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(6))
+			Expect(clock.AfterCallCount()).To(Equal(3))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+		It("never marks past a message that keeps failing", func() {
+			messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+				if msg.Offset == 2 {
+					return errors.New("banana")
+				}
+				return nil
+			}
+			done := process()
+			Eventually(messageHandler.ConsumeMessageCallCount).Should(BeNumerically(">", 10))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(markedOffsets()).To(Equal([]int64{1}))
+			for i := 0; i < messageHandler.ConsumeMessageCallCount(); i++ {
+				_, msg := messageHandler.ConsumeMessageArgsForCall(i)
+				Expect(msg.Offset).To(BeNumerically("<=", 2))
+			}
+		})
+	})
+	Context("parallel", func() {
+		BeforeEach(func() {
+			processor.Workers = 2
+		})
+		It("delivers other keys while one is slow and marks only the finished prefix", func() {
+			release := make(chan struct{})
+			var delivered sync.Map
+			messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+				if msg.Offset == 1 {
+					<-release
+				}
+				delivered.Store(msg.Offset, true)
+				return nil
+			}
+			done := process()
+			Eventually(func() bool {
+				_, ok := delivered.Load(int64(3))
+				return ok
+			}).Should(BeTrue())
+			Consistently(markedOffsets, 100*time.Millisecond).Should(BeEmpty())
+			close(release)
+			Eventually(markedOffsets).Should(Equal([]int64{3}))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+		It("delivers messages with the same key in order", func() {
+			var mux sync.Mutex
+			var offsets []int64
+			messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+				if string(msg.Key) == "b" {
+					mux.Lock()
+					offsets = append(offsets, msg.Offset)
+					mux.Unlock()
+				}
+				return nil
+			}
+			done := process()
+			Eventually(markedOffsets).Should(ContainElement(int64(3)))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			mux.Lock()
+			defer mux.Unlock()
+			Expect(offsets).To(Equal([]int64{2, 3}))
+		})
+		It("never marks past a blocking message in strict mode", func() {
+			processor.Strict = true
+			messageHandler.ConsumeMessageStub = func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+				if msg.Offset == 1 {
+					return errors.New("banana")
+				}
+				return nil
+			}
+			done := process()
+			Eventually(messageHandler.ConsumeMessageCallCount).Should(BeNumerically(">", 10))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(markedOffsets()).To(BeEmpty())
+		})
+	})
+	Context("batch", func() {
+		var batchHandler *mocks.BatchMessageHandler
+		var linger chan time.Time
+		var mux sync.Mutex
+		var batches [][]int64
+		BeforeEach(func() {
+			batches = nil
+			batchHandler = &mocks.BatchMessageHandler{}
+			batchHandler.ConsumeMessagesStub = func(ctx context.Context, msgs []*sarama.ConsumerMessage) error {
+				mux.Lock()
+				defer mux.Unlock()
+				var offsets []int64
+				for _, msg := range msgs {
+					offsets = append(offsets, msg.Offset)
+				}
+				batches = append(batches, offsets)
+				return nil
+			}
+			linger = make(chan time.Time, 1)
+			clock.AfterStub = func(d time.Duration) <-chan time.Time {
+				return linger
+			}
+			processor.BatchHandler = batchHandler
+			processor.BatchSize = 2
+		})
+		deliveredBatches := func() [][]int64 {
+			mux.Lock()
+			defer mux.Unlock()
+			return append([][]int64{}, batches...)
+		}
+		It("delivers full batches and the rest after linger", func() {
+			done := process()
+			Eventually(markedOffsets).Should(Equal([]int64{2}))
+			Consistently(deliveredBatches, 50*time.Millisecond).Should(Equal([][]int64{{1, 2}}))
+			linger <- time.Now()
+			Eventually(markedOffsets).Should(Equal([]int64{2, 3}))
+			Expect(deliveredBatches()).To(Equal([][]int64{{1, 2}, {3}}))
+			Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(0))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+		It("limits batches by bytes", func() {
+			processor.BatchSize = 10
+			processor.BatchBytes = 1
+			done := process()
+			Eventually(markedOffsets).Should(Equal([]int64{1, 2, 3}))
+			Expect(deliveredBatches()).To(Equal([][]int64{{1}, {2}, {3}}))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+		It("never marks a failing batch in strict mode", func() {
+			processor.Strict = true
+			clock.AfterStub = func(d time.Duration) <-chan time.Time {
+				c := make(chan time.Time, 1)
+				c <- time.Now()
+				return c
+			}
+			batchHandler.ConsumeMessagesReturns(errors.New("banana"))
+			done := process()
+			Eventually(batchHandler.ConsumeMessagesCallCount).Should(BeNumerically(">", 10))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(markedOffsets()).To(BeEmpty())
+		})
+		It("delivers the matching messages of batches", func() {
+			filter := &mocks.MessageFilter{}
+			filter.MatchStub = func(msg *sarama.ConsumerMessage) bool {
+				return msg.Offset != 2
+			}
+			processor.Filter = filter
+			processor.BatchSize = 3
+			done := process()
+			Eventually(markedOffsets).Should(Equal([]int64{3}))
+			Expect(deliveredBatches()).To(Equal([][]int64{{1, 3}}))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+		It("filters batches once in strict mode", func() {
+			processor.Strict = true
+			processor.BatchSize = 3
+			processor.BatchLinger = time.Hour
+			clock.AfterStub = func(d time.Duration) <-chan time.Time {
+				if d == processor.BatchLinger {
+					return linger
+				}
+				c := make(chan time.Time, 1)
+				c <- time.Now()
+				return c
+			}
+			filter := &mocks.MessageFilter{}
+			filter.MatchReturns(true)
+			processor.Filter = filter
+			batchHandler.ConsumeMessagesReturns(errors.New("banana"))
+			done := process()
+			Eventually(batchHandler.ConsumeMessagesCallCount).Should(BeNumerically(">", 10))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(filter.MatchCallCount()).To(Equal(3))
+		})
+	})
+})
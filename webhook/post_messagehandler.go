@@ -7,6 +7,7 @@ package webhook
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -14,7 +15,9 @@ import (
 )
 
 type PostMessageHandler struct {
-	HttpClient     HttpClient
+	HttpClient HttpClient
+	// Limiter delays requests before the timeout starts, unlimited if nil
+	Limiter        RequestLimiter
 	Timeout        time.Duration
 	RequestBuilder interface {
 		Encode(msg *sarama.ConsumerMessage) (*http.Request, error)
@@ -27,15 +30,71 @@ func (p *PostMessageHandler) ConsumeMessage(ctx context.Context, msg *sarama.Con
 		return errors.Wrap(err, "build request failed")
 	}
 
-	ctx, cancelFunc := context.WithTimeout(ctx, p.Timeout)
+	return post(ctx, p.HttpClient, p.Limiter, p.Timeout, req)
+}
+
+// PostBatchHandler sends a batch of messages with one request.
+type PostBatchHandler struct {
+	HttpClient HttpClient
+	// Limiter delays requests before the timeout starts, unlimited if nil
+	Limiter        RequestLimiter
+	Timeout        time.Duration
+	RequestBuilder interface {
+		EncodeBatch(msgs []*sarama.ConsumerMessage) (*http.Request, error)
+	}
+}
+
+func (p *PostBatchHandler) ConsumeMessages(ctx context.Context, msgs []*sarama.ConsumerMessage) error {
+	req, err := p.RequestBuilder.EncodeBatch(msgs)
+	if err != nil {
+		return errors.Wrap(err, "build request failed")
+	}
+	return post(ctx, p.HttpClient, p.Limiter, p.Timeout, req)
+}
+
+// post sends the request and returns a StatusError if the response is not 2xx.
+func post(ctx context.Context, httpClient HttpClient, limiter RequestLimiter, timeout time.Duration, req *http.Request) error {
+	if limiter != nil {
+		if err := limiter.Wait(ctx, req); err != nil {
+			return err
+		}
+	}
+	ctx, cancelFunc := context.WithTimeout(ctx, timeout)
 	defer cancelFunc()
 
-	resp, err := p.HttpClient.Do(req.WithContext(ctx))
+	resp, err := httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return errors.Wrap(err, "perform request failed")
 	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if limiter != nil {
+		limiter.Observe(req, resp.StatusCode)
+	}
 	if resp.StatusCode/100 != 2 {
-		return errors.Wrap(err, "status != 2xx")
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 	return nil
 }
+
+// parseRetryAfter returns the delay of a Retry-After header given in seconds or as HTTP-date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || date.Before(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
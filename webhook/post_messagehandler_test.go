@@ -7,6 +7,7 @@ package webhook_test
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/bborbe/kafka-webhook/mocks"
@@ -39,4 +40,52 @@ var _ = Describe("PostMessageHandler", func() {
 		err := messageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
 		Expect(err).To(BeNil())
 	})
+
+	It("returns status error if status is not 2xx", func() {
+		httpClient.DoReturns(&http.Response{
+			StatusCode: 500,
+		}, nil)
+		err := messageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		Expect(err).To(Equal(&webhook.StatusError{StatusCode: 500}))
+	})
+
+	It("returns retry after given in seconds", func() {
+		httpClient.DoReturns(&http.Response{
+			StatusCode: 429,
+			Header:     http.Header{"Retry-After": []string{"120"}},
+		}, nil)
+		err := messageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		Expect(err).To(Equal(&webhook.StatusError{StatusCode: 429, RetryAfter: 2 * time.Minute}))
+	})
+	It("returns retry after given as http date", func() {
+		httpClient.DoReturns(&http.Response{
+			StatusCode: 503,
+			Header:     http.Header{"Retry-After": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}},
+		}, nil)
+		err := messageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		statusError, ok := err.(*webhook.StatusError)
+		Expect(ok).To(BeTrue())
+		Expect(statusError.RetryAfter).To(BeNumerically("~", time.Hour, time.Minute))
+	})
+	It("waits for the limiter and reports the status", func() {
+		limiter := &mocks.RequestLimiter{}
+		messageHandler.Limiter = limiter
+		httpClient.DoReturns(&http.Response{
+			StatusCode: 429,
+		}, nil)
+		err := messageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		Expect(err).To(Equal(&webhook.StatusError{StatusCode: 429}))
+		Expect(limiter.WaitCallCount()).To(Equal(1))
+		Expect(limiter.ObserveCallCount()).To(Equal(1))
+		_, statusCode := limiter.ObserveArgsForCall(0)
+		Expect(statusCode).To(Equal(429))
+	})
+	It("sends no request if the limiter is canceled", func() {
+		limiter := &mocks.RequestLimiter{}
+		limiter.WaitReturns(context.Canceled)
+		messageHandler.Limiter = limiter
+		err := messageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		Expect(err).To(Equal(context.Canceled))
+		Expect(httpClient.DoCallCount()).To(Equal(0))
+	})
 })
@@ -0,0 +1,223 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Scopes a rate limit is shared in.
+const (
+	RateLimitScopeRoute = "route"
+	RateLimitScopeHost  = "host"
+)
+
+const (
+	// minRateLimitFactor is the lowest fraction of the configured rate 429 responses slow down to.
+	minRateLimitFactor = 1.0 / 16
+	// rateLimitRecovery is the fraction of the configured rate regained with every successful request.
+	rateLimitRecovery = 0.05
+)
+
+// IsRateLimitScope returns true if the scope is known, empty is route.
+func IsRateLimitScope(scope string) bool {
+	switch scope {
+	case "", RateLimitScopeRoute, RateLimitScopeHost:
+		return true
+	}
+	return false
+}
+
+//go:generate counterfeiter -o ../mocks/request_limiter.go --fake-name RequestLimiter . RequestLimiter
+
+// RequestLimiter delays requests before they are sent.
+type RequestLimiter interface {
+	// Wait blocks until the request may be sent or ctx is done.
+	Wait(ctx context.Context, req *http.Request) error
+	// Observe reports the status code of the response to the request.
+	Observe(req *http.Request, statusCode int)
+}
+
+// RateLimit defines the allowed throughput, zero values are unlimited.
+type RateLimit struct {
+	// Requests per second
+	Requests float64
+	// Burst is the amount of requests sent at once after idle time, at least one
+	Burst int
+	// Bytes of request bodies per second, bursts of one second
+	Bytes int
+}
+
+// Enabled returns true if requests or bytes are limited.
+func (r RateLimit) Enabled() bool {
+	return r.Requests > 0 || r.Bytes > 0
+}
+
+// RateLimiter limits requests and bytes with token buckets shared by all callers.
+// A 429 response halves the rate down to 1/16 of the limit, every success regains 5% of it.
+type RateLimiter struct {
+	Limit RateLimit
+	// Clock used to wait, SystemClock if nil
+	Clock Clock
+
+	mux      sync.Mutex
+	requests tokenBucket
+	bytes    tokenBucket
+	factor   float64
+}
+
+// Wait takes a token for the request and its body and blocks until they are available.
+func (r *RateLimiter) Wait(ctx context.Context, req *http.Request) error {
+	size := float64(req.ContentLength)
+	if size < 0 {
+		size = 0
+	}
+	clock := r.clock()
+	r.mux.Lock()
+	now := clock.Now()
+	factor := r.rateFactor()
+	wait := r.requests.take(now, 1, r.Limit.Requests*factor, r.burst())
+	if bytesWait := r.bytes.take(now, size, float64(r.Limit.Bytes)*factor, float64(r.Limit.Bytes)); bytesWait > wait {
+		wait = bytesWait
+	}
+	r.mux.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	glog.V(3).Infof("rate limit of %s reached => wait %v", req.URL.Host, wait)
+	select {
+	case <-ctx.Done():
+		r.mux.Lock()
+		r.requests.tokens++
+		r.bytes.tokens += size
+		r.mux.Unlock()
+		return errors.Wrap(ctx.Err(), "wait for rate limit canceled")
+	case <-clock.After(wait):
+		return nil
+	}
+}
+
+// Observe slows down on 429 and speeds up again on success.
+func (r *RateLimiter) Observe(req *http.Request, statusCode int) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	factor := r.rateFactor()
+	// tokens up to now are earned at the previous rate
+	now := r.clock().Now()
+	r.requests.refill(now, r.Limit.Requests*factor, r.burst())
+	r.bytes.refill(now, float64(r.Limit.Bytes)*factor, float64(r.Limit.Bytes))
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		r.factor = math.Max(factor/2, minRateLimitFactor)
+		// no more bursts until the receiver recovered
+		r.requests.tokens = math.Min(r.requests.tokens, 0)
+		r.bytes.tokens = math.Min(r.bytes.tokens, 0)
+		glog.V(1).Infof("too many requests to %s => slow down to %.0f%% of rate limit", req.URL.Host, r.factor*100)
+	case statusCode/100 == 2:
+		r.factor = math.Min(factor+rateLimitRecovery, 1)
+	}
+}
+
+func (r *RateLimiter) rateFactor() float64 {
+	if r.factor <= 0 {
+		return 1
+	}
+	return r.factor
+}
+
+func (r *RateLimiter) burst() float64 {
+	if r.Limit.Burst < 1 {
+		return 1
+	}
+	return float64(r.Limit.Burst)
+}
+
+func (r *RateLimiter) clock() Clock {
+	if r.Clock != nil {
+		return r.Clock
+	}
+	return SystemClock{}
+}
+
+// tokenBucket hands out tokens at a rate up to a burst. Tokens are reserved in advance, so callers are
+// served in order and the tokens go negative while callers wait.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take reserves the tokens and returns the time until they are available, zero if the rate is unlimited.
+func (t *tokenBucket) take(now time.Time, amount float64, rate float64, burst float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	t.refill(now, rate, burst)
+	t.tokens -= amount
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / rate * float64(time.Second))
+}
+
+// refill adds the tokens earned since the last refill, a new bucket starts full.
+func (t *tokenBucket) refill(now time.Time, rate float64, burst float64) {
+	if rate <= 0 {
+		return
+	}
+	if t.last.IsZero() {
+		t.tokens = burst
+	} else if elapsed := now.Sub(t.last); elapsed > 0 {
+		t.tokens = math.Min(t.tokens+elapsed.Seconds()*rate, burst)
+	}
+	t.last = now
+}
+
+// RateLimiters holds one RateLimiter per key, e.g. the host of the requests.
+type RateLimiters struct {
+	Clock Clock
+
+	mux      sync.Mutex
+	limiters map[string]*RateLimiter
+}
+
+// Get returns the RateLimiter of the key and creates it with the limit if needed.
+func (r *RateLimiters) Get(key string, limit RateLimit) *RateLimiter {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.limiters == nil {
+		r.limiters = make(map[string]*RateLimiter)
+	}
+	limiter, ok := r.limiters[key]
+	if !ok {
+		limiter = &RateLimiter{
+			Limit: limit,
+			Clock: r.Clock,
+		}
+		r.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// HostRateLimiter limits requests by the host they are sent to. Routes sending to the same host share
+// the limit, the first route creates it.
+type HostRateLimiter struct {
+	Limiters *RateLimiters
+	Limit    RateLimit
+}
+
+func (h *HostRateLimiter) Wait(ctx context.Context, req *http.Request) error {
+	return h.Limiters.Get(req.URL.Host, h.Limit).Wait(ctx, req)
+}
+
+func (h *HostRateLimiter) Observe(req *http.Request, statusCode int) {
+	h.Limiters.Get(req.URL.Host, h.Limit).Observe(req, statusCode)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RateLimiter", func() {
+	var now time.Time
+	var waits []time.Duration
+	var clock *mocks.Clock
+	var limiter *webhook.RateLimiter
+	request := func(size int) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://www.example.com", bytes.NewReader(make([]byte, size)))
+		Expect(err).To(BeNil())
+		return req
+	}
+	// send waits for the limiter, the clock advances by the waited time
+	send := func(size int) time.Duration {
+		before := len(waits)
+		Expect(limiter.Wait(context.Background(), request(size))).To(BeNil())
+		if len(waits) == before {
+			return 0
+		}
+		return waits[len(waits)-1]
+	}
+	BeforeEach(func() {
+		now = time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+		waits = nil
+		clock = &mocks.Clock{}
+		clock.NowStub = func() time.Time {
+			return now
+		}
+		clock.AfterStub = func(d time.Duration) <-chan time.Time {
+			waits = append(waits, d)
+			now = now.Add(d)
+			result := make(chan time.Time, 1)
+			result <- now
+			return result
+		}
+		limiter = &webhook.RateLimiter{
+			Limit: webhook.RateLimit{
+				Requests: 10,
+				Burst:    2,
+			},
+			Clock: clock,
+		}
+	})
+	It("sends the burst without waiting", func() {
+		Expect(send(0)).To(Equal(time.Duration(0)))
+		Expect(send(0)).To(Equal(time.Duration(0)))
+		Expect(send(0)).To(Equal(100 * time.Millisecond))
+		Expect(send(0)).To(Equal(100 * time.Millisecond))
+	})
+	It("refills tokens over time", func() {
+		send(0)
+		send(0)
+		now = now.Add(time.Second)
+		Expect(send(0)).To(Equal(time.Duration(0)))
+		Expect(send(0)).To(Equal(time.Duration(0)))
+		Expect(send(0)).To(Equal(100 * time.Millisecond))
+	})
+	It("limits bytes", func() {
+		limiter.Limit = webhook.RateLimit{Bytes: 1000}
+		Expect(send(1000)).To(Equal(time.Duration(0)))
+		Expect(send(500)).To(Equal(500 * time.Millisecond))
+		Expect(send(2000)).To(Equal(2 * time.Second))
+	})
+	It("slows down on too many requests and recovers on success", func() {
+		send(0)
+		send(0)
+		req := request(0)
+		limiter.Observe(req, http.StatusTooManyRequests)
+		Expect(send(0)).To(Equal(200 * time.Millisecond))
+		limiter.Observe(req, http.StatusTooManyRequests)
+		Expect(send(0)).To(Equal(400 * time.Millisecond))
+		for i := 0; i < 20; i++ {
+			limiter.Observe(req, http.StatusOK)
+		}
+		Expect(send(0)).To(Equal(100 * time.Millisecond))
+	})
+	It("returns error if the context is canceled", func() {
+		send(0)
+		send(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		clock.AfterStub = func(d time.Duration) <-chan time.Time {
+			return make(chan time.Time)
+		}
+		Expect(limiter.Wait(ctx, request(0))).NotTo(BeNil())
+	})
+})
+
+var _ = Describe("HostRateLimiter", func() {
+	It("shares the limit of a host", func() {
+		limiters := &webhook.RateLimiters{}
+		first := &webhook.HostRateLimiter{Limiters: limiters, Limit: webhook.RateLimit{Requests: 1}}
+		second := &webhook.HostRateLimiter{Limiters: limiters, Limit: webhook.RateLimit{Requests: 5}}
+		req, err := http.NewRequest(http.MethodPost, "http://www.example.com/a", nil)
+		Expect(err).To(BeNil())
+		Expect(first.Wait(context.Background(), req)).To(BeNil())
+		Expect(second.Wait(context.Background(), req)).To(BeNil())
+		Expect(limiters.Get("www.example.com", webhook.RateLimit{}).Limit.Requests).To(Equal(1.0))
+	})
+})
@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// DefaultHeaderPrefix is prepended to the key of record headers sent as HTTP headers.
+const DefaultHeaderPrefix = "X-Message-Header-"
+
+// base64ValuePrefix marks header values that are base64 encoded because they are not printable.
+const base64ValuePrefix = "base64:"
+
+// NewHeaderFilter returns a filter for the given comma separated lists of header keys.
+func NewHeaderFilter(allow string, deny string) *HeaderFilter {
+	return &HeaderFilter{
+		Allow: splitList(allow),
+		Deny:  splitList(deny),
+	}
+}
+
+// HeaderFilter selects record headers by key, case-insensitive. Deny wins over Allow, an empty Allow allows all.
+type HeaderFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// Match returns true if the record header with the given key is selected.
+func (h *HeaderFilter) Match(key string) bool {
+	if h == nil {
+		return true
+	}
+	for _, deny := range h.Deny {
+		if strings.EqualFold(deny, key) {
+			return false
+		}
+	}
+	if len(h.Allow) == 0 {
+		return true
+	}
+	for _, allow := range h.Allow {
+		if strings.EqualFold(allow, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeRecordHeaders adds all selected record headers with the given prefix to the HTTP header.
+// If a key is not lowercase, all keys are listed in RecordHeaderNamesField to restore their case.
+func encodeRecordHeaders(header http.Header, prefix string, filter *HeaderFilter, recordHeaders []*sarama.RecordHeader) {
+	var names []string
+	mixedCase := false
+	for _, recordHeader := range recordHeaders {
+		if recordHeader == nil {
+			continue
+		}
+		key := string(recordHeader.Key)
+		if !filter.Match(key) {
+			continue
+		}
+		if !isToken(key) || strings.EqualFold(prefix+key, RecordHeaderNamesField) {
+			glog.V(2).Infof("skip record header %q: no valid http header name", key)
+			continue
+		}
+		header.Add(prefix+key, encodeHeaderValue(recordHeader.Value))
+		names = append(names, key)
+		mixedCase = mixedCase || key != strings.ToLower(key)
+	}
+	if mixedCase {
+		header.Set(RecordHeaderNamesField, strings.Join(names, ","))
+	}
+}
+
+// decodeRecordHeaders returns all HTTP headers with the given prefix as record headers.
+// Keys get the case listed in RecordHeaderNamesField, keys not listed are lowercase.
+func decodeRecordHeaders(header http.Header, prefix string) ([]*sarama.RecordHeader, error) {
+	var result []*sarama.RecordHeader
+	// original names by lowercase key in the order of the values
+	originalNames := make(map[string][]string)
+	for _, name := range splitList(header.Get(RecordHeaderNamesField)) {
+		key := strings.ToLower(name)
+		originalNames[key] = append(originalNames[key], name)
+	}
+	canonicalPrefix := http.CanonicalHeaderKey(prefix)
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := header[name]
+		if !strings.HasPrefix(http.CanonicalHeaderKey(name), canonicalPrefix) || len(name) == len(prefix) ||
+			http.CanonicalHeaderKey(name) == RecordHeaderNamesField {
+			continue
+		}
+		lowerKey := strings.ToLower(name[len(prefix):])
+		for _, value := range values {
+			key := lowerKey
+			if names := originalNames[lowerKey]; len(names) > 0 {
+				key = names[0]
+				originalNames[lowerKey] = names[1:]
+			}
+			decoded, err := decodeHeaderValue(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decode header %s failed", name)
+			}
+			result = append(result, &sarama.RecordHeader{
+				Key:   []byte(key),
+				Value: decoded,
+			})
+		}
+	}
+	return result, nil
+}
+
+// encodeHeaderValue returns printable values unchanged and all others base64 encoded.
+func encodeHeaderValue(value []byte) string {
+	if isPrintable(value) && !strings.HasPrefix(string(value), base64ValuePrefix) {
+		return string(value)
+	}
+	return base64ValuePrefix + base64.StdEncoding.EncodeToString(value)
+}
+
+func decodeHeaderValue(value string) ([]byte, error) {
+	if strings.HasPrefix(value, base64ValuePrefix) {
+		return base64.StdEncoding.DecodeString(value[len(base64ValuePrefix):])
+	}
+	return []byte(value), nil
+}
+
+// isPrintable returns true for visible ASCII without leading or trailing spaces, which HTTP would trim.
+func isPrintable(value []byte) bool {
+	for _, b := range value {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+	return len(value) == 0 || (value[0] != ' ' && value[len(value)-1] != ' ')
+}
+
+// isToken returns true if value is a valid HTTP header name.
+func isToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r > 0x7e || r <= 0x20 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList splits a comma separated list and drops empty entries.
+func splitList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
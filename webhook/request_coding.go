@@ -7,30 +7,44 @@ package webhook
 import (
 	"bytes"
 	"encoding/base64"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
 )
 
 const (
-	KeyField       = "X-Message-Key"
-	TopicField     = "X-Message-Topic"
-	OffsetField    = "X-Message-Offset"
-	PartitionField = "X-Message-Partition"
-	SignaturField  = "X-Signature"
+	KeyField                = "X-Message-Key"
+	TopicField              = "X-Message-Topic"
+	OffsetField             = "X-Message-Offset"
+	PartitionField          = "X-Message-Partition"
+	SignaturField           = "X-Signature"
+	TimestampField          = "X-Message-Timestamp"
+	BlockTimestampField     = "X-Message-Block-Timestamp"
+	SignatureVersionField   = "X-Signature-Version"
+	SignatureTimestampField = "X-Signature-Timestamp"
+	DeliveryIdField         = "X-Delivery-Id"
+	// RecordHeaderNamesField lists the record header keys in their original case, HTTP header names are case-insensitive
+	RecordHeaderNamesField = "X-Message-Headers"
 )
 
 type RequestCoding struct {
 	Url    string
 	Method string
-	Signer interface {
-		Compare(content []byte, sign string) (bool, error)
-		Sign(content []byte) string
-	}
+	Signer RequestSigner
+	// HeaderPrefix is prepended to the keys of record headers sent as HTTP headers, record headers are dropped if empty
+	HeaderPrefix string
+	// HeaderFilter selects the record headers to send, all if nil
+	HeaderFilter *HeaderFilter
+	// SignatureVersion selects the signature scheme, SignatureV1 if zero
+	SignatureVersion int
+	// Tolerance is the maximum age of a request with SignatureV2, DefaultTolerance if zero
+	Tolerance time.Duration
+	// Clock used for signature timestamps, SystemClock if nil
+	Clock Clock
 }
 
 func (r *RequestCoding) Encode(msg *sarama.ConsumerMessage) (*http.Request, error) {
@@ -38,11 +52,13 @@ func (r *RequestCoding) Encode(msg *sarama.ConsumerMessage) (*http.Request, erro
 	if err != nil {
 		return nil, errors.Wrap(err, "build request failed")
 	}
-	req.Header.Add(KeyField, base64.StdEncoding.EncodeToString(msg.Key))
-	req.Header.Add(TopicField, msg.Topic)
-	req.Header.Add(OffsetField, strconv.FormatInt(msg.Offset, 10))
-	req.Header.Add(PartitionField, strconv.FormatInt(int64(msg.Partition), 10))
-	req.Header.Add(SignaturField, r.Signer.Sign(msg.Value))
+	encodeMessageFields(req.Header, msg)
+	if r.HeaderPrefix != "" {
+		encodeRecordHeaders(req.Header, r.HeaderPrefix, r.HeaderFilter, msg.Headers)
+	}
+	if err := r.signature().sign(req.Header, DeliveryID(msg), msg.Value); err != nil {
+		return nil, errors.Wrap(err, "sign request failed")
+	}
 	return req, nil
 }
 
@@ -52,30 +68,77 @@ func (r *RequestCoding) Decode(req *http.Request) (*sarama.ConsumerMessage, erro
 		return nil, errors.Wrap(err, "read body failed")
 	}
 	defer req.Body.Close()
-	equal, err := r.Signer.Compare(content, req.Header.Get(SignaturField))
+	if err := r.signature().verify(req.Header, content); err != nil {
+		return nil, &SignatureError{Err: err}
+	}
+	msg, err := decodeMessageFields(req.Header)
 	if err != nil {
-		return nil, fmt.Errorf("compare signs failed")
+		return nil, err
+	}
+	if err := verifyDeliveryID(req.Header, msg); err != nil {
+		return nil, err
 	}
-	if !equal {
-		return nil, fmt.Errorf("check secret failed")
+	msg.Value = content
+	if r.HeaderPrefix != "" {
+		if msg.Headers, err = decodeRecordHeaders(req.Header, r.HeaderPrefix); err != nil {
+			return nil, errors.Wrap(err, "decode headers failed")
+		}
 	}
-	key, err := base64.StdEncoding.DecodeString(req.Header.Get(KeyField))
+	return msg, nil
+}
+
+func (r *RequestCoding) signature() signature {
+	return signature{
+		Signer:    r.Signer,
+		Version:   r.SignatureVersion,
+		Tolerance: r.Tolerance,
+		Clock:     r.Clock,
+	}
+}
+
+// encodeMessageFields adds key, topic, partition, offset and timestamps of the record to the HTTP header.
+func encodeMessageFields(header http.Header, msg *sarama.ConsumerMessage) {
+	header.Add(KeyField, base64.StdEncoding.EncodeToString(msg.Key))
+	header.Add(TopicField, msg.Topic)
+	header.Add(OffsetField, strconv.FormatInt(msg.Offset, 10))
+	header.Add(PartitionField, strconv.FormatInt(int64(msg.Partition), 10))
+	if !msg.Timestamp.IsZero() {
+		header.Add(TimestampField, msg.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+	if !msg.BlockTimestamp.IsZero() {
+		header.Add(BlockTimestampField, msg.BlockTimestamp.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+// decodeMessageFields returns a record with key, topic, partition, offset and timestamps of the HTTP header.
+func decodeMessageFields(header http.Header) (*sarama.ConsumerMessage, error) {
+	key, err := base64.StdEncoding.DecodeString(header.Get(KeyField))
 	if err != nil {
 		return nil, errors.Wrap(err, "decode key failed")
 	}
-	offset, err := strconv.Atoi(req.Header.Get(OffsetField))
+	offset, err := strconv.Atoi(header.Get(OffsetField))
 	if err != nil {
 		return nil, errors.Wrap(err, "decode offset failed")
 	}
-	partition, err := strconv.Atoi(req.Header.Get(PartitionField))
+	partition, err := strconv.Atoi(header.Get(PartitionField))
 	if err != nil {
 		return nil, errors.Wrap(err, "decode partition failed")
 	}
-	return &sarama.ConsumerMessage{
-		Value:     content,
+	msg := &sarama.ConsumerMessage{
 		Key:       key,
-		Topic:     req.Header.Get(TopicField),
+		Topic:     header.Get(TopicField),
 		Offset:    int64(offset),
 		Partition: int32(partition),
-	}, nil
+	}
+	if value := header.Get(TimestampField); value != "" {
+		if msg.Timestamp, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return nil, errors.Wrap(err, "decode timestamp failed")
+		}
+	}
+	if value := header.Get(BlockTimestampField); value != "" {
+		if msg.BlockTimestamp, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return nil, errors.Wrap(err, "decode block timestamp failed")
+		}
+	}
+	return msg, nil
 }
@@ -7,8 +7,10 @@ package webhook_test
 import (
 	"encoding/base64"
 	"net/http"
+	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/mocks"
 	"github.com/bborbe/kafka-webhook/webhook"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -82,4 +84,172 @@ var _ = Describe("RequestCoding", func() {
 		Expect(message.Offset).To(Equal(offset))
 		Expect(message.Partition).To(Equal(partition))
 	})
+	Context("with headers", func() {
+		timestamp := time.Date(2018, 10, 1, 12, 30, 0, 123, time.UTC)
+		BeforeEach(func() {
+			requestCoding.HeaderPrefix = webhook.DefaultHeaderPrefix
+			msg.Timestamp = timestamp
+			msg.Headers = []*sarama.RecordHeader{
+				{Key: []byte("trace-id"), Value: []byte("abc-123")},
+				{Key: []byte("tenant"), Value: []byte("acme")},
+				{Key: []byte("binary"), Value: []byte{0, 1, 2, 255}},
+				{Key: []byte("invalid name"), Value: []byte("value")},
+			}
+		})
+		It("set timestamp as header", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get(webhook.TimestampField)).To(Equal("2018-10-01T12:30:00.000000123Z"))
+		})
+		It("set record headers with prefix", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get("X-Message-Header-Trace-Id")).To(Equal("abc-123"))
+			Expect(req.Header.Get("X-Message-Header-Tenant")).To(Equal("acme"))
+			Expect(req.Header.Get("X-Message-Header-Binary")).To(Equal("base64:AAEC/w=="))
+		})
+		It("skips record headers without valid name", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			for name := range req.Header {
+				Expect(name).NotTo(ContainSubstring("Invalid"))
+			}
+		})
+		It("skips denied record headers", func() {
+			requestCoding.HeaderFilter = webhook.NewHeaderFilter("", "tenant")
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get("X-Message-Header-Trace-Id")).To(Equal("abc-123"))
+			Expect(req.Header.Get("X-Message-Header-Tenant")).To(BeEmpty())
+		})
+		It("sends only allowed record headers", func() {
+			requestCoding.HeaderFilter = webhook.NewHeaderFilter("Trace-Id", "")
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get("X-Message-Header-Trace-Id")).To(Equal("abc-123"))
+			Expect(req.Header.Get("X-Message-Header-Tenant")).To(BeEmpty())
+			Expect(req.Header.Get("X-Message-Header-Binary")).To(BeEmpty())
+		})
+		It("sends no record headers without prefix", func() {
+			requestCoding.HeaderPrefix = ""
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get("X-Message-Header-Trace-Id")).To(BeEmpty())
+		})
+		It("encodes headers and timestamp to request and back", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			message, err := requestCoding.Decode(req)
+			Expect(err).To(BeNil())
+			Expect(message.Timestamp).To(Equal(timestamp))
+			Expect(message.Headers).To(ConsistOf(
+				&sarama.RecordHeader{Key: []byte("trace-id"), Value: []byte("abc-123")},
+				&sarama.RecordHeader{Key: []byte("tenant"), Value: []byte("acme")},
+				&sarama.RecordHeader{Key: []byte("binary"), Value: []byte{0, 1, 2, 255}},
+			))
+			Expect(req.Header.Get(webhook.RecordHeaderNamesField)).To(BeEmpty())
+		})
+		It("keeps the case of record header keys", func() {
+			msg.Headers = []*sarama.RecordHeader{
+				{Key: []byte("TraceID"), Value: []byte("abc")},
+				{Key: []byte("tenant"), Value: []byte("acme")},
+				{Key: []byte("traceid"), Value: []byte("def")},
+				{Key: []byte("Content-Type"), Value: []byte("text/plain")},
+			}
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get(webhook.RecordHeaderNamesField)).To(Equal("TraceID,tenant,traceid,Content-Type"))
+			message, err := requestCoding.Decode(req)
+			Expect(err).To(BeNil())
+			Expect(message.Headers).To(ConsistOf(
+				&sarama.RecordHeader{Key: []byte("TraceID"), Value: []byte("abc")},
+				&sarama.RecordHeader{Key: []byte("tenant"), Value: []byte("acme")},
+				&sarama.RecordHeader{Key: []byte("traceid"), Value: []byte("def")},
+				&sarama.RecordHeader{Key: []byte("Content-Type"), Value: []byte("text/plain")},
+			))
+		})
+	})
+	Context("with signature v2", func() {
+		var clock *mocks.Clock
+		var now time.Time
+		BeforeEach(func() {
+			now = time.Unix(1538395200, 0)
+			clock = &mocks.Clock{}
+			clock.NowStub = func() time.Time {
+				return now
+			}
+			requestCoding.SignatureVersion = webhook.SignatureV2
+			requestCoding.Tolerance = time.Minute
+			requestCoding.Clock = clock
+		})
+		It("set timestamp and delivery id as header", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req.Header.Get(webhook.SignatureVersionField)).To(Equal("2"))
+			Expect(req.Header.Get(webhook.SignatureTimestampField)).To(Equal("1538395200"))
+			Expect(req.Header.Get(webhook.DeliveryIdField)).To(Equal(webhook.DeliveryID(msg)))
+		})
+		It("signs timestamp, delivery id and body", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			signer := &webhook.Signer{Secret: "secret"}
+			payload := "1538395200." + req.Header.Get(webhook.DeliveryIdField) + ".value"
+			Expect(req.Header.Get(webhook.SignaturField)).To(Equal(signer.Sign([]byte(payload))))
+		})
+		It("uses the same delivery id for retries", func() {
+			req1, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			req2, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req1.Header.Get(webhook.DeliveryIdField)).To(Equal(req2.Header.Get(webhook.DeliveryIdField)))
+			msg.Offset++
+			req3, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			Expect(req3.Header.Get(webhook.DeliveryIdField)).NotTo(Equal(req1.Header.Get(webhook.DeliveryIdField)))
+		})
+		It("rejects requests with delivery id of another record", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			req.Header.Set(webhook.OffsetField, "999")
+			_, err = requestCoding.Decode(req)
+			Expect(err).To(HaveOccurred())
+		})
+		It("encodes sarama message to request and back", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			now = now.Add(30 * time.Second)
+			message, err := requestCoding.Decode(req)
+			Expect(err).To(BeNil())
+			Expect(message.Value).To(Equal(value))
+		})
+		It("rejects requests outside of tolerance", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			now = now.Add(2 * time.Minute)
+			_, err = requestCoding.Decode(req)
+			Expect(err).To(HaveOccurred())
+		})
+		It("rejects requests with modified timestamp", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			req.Header.Set(webhook.SignatureTimestampField, "1538395230")
+			_, err = requestCoding.Decode(req)
+			Expect(err).To(HaveOccurred())
+		})
+		It("rejects requests with modified delivery id", func() {
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			req.Header.Set(webhook.DeliveryIdField, "replayed")
+			_, err = requestCoding.Decode(req)
+			Expect(err).To(HaveOccurred())
+		})
+		It("rejects requests signed with v1", func() {
+			requestCoding.SignatureVersion = webhook.SignatureV1
+			req, err := requestCoding.Encode(msg)
+			Expect(err).To(BeNil())
+			requestCoding.SignatureVersion = webhook.SignatureV2
+			_, err = requestCoding.Decode(req)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
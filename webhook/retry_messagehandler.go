@@ -10,43 +10,120 @@ import (
 
 	"github.com/Shopify/sarama"
 	"github.com/golang/glog"
-	"github.com/pkg/errors"
 )
 
-// RetryMessageHandler calls the given MessageHandler until no errors occurred, MaxRetry reached or Context is done.
+// RetryMessageHandler calls the given MessageHandler or BatchHandler until no errors occurred, MaxRetry reached or Context is done.
 type RetryMessageHandler struct {
 	// MessageHandler to call
 	MessageHandler MessageHandler
+	// BatchHandler to call with batches
+	BatchHandler BatchMessageHandler
 	// MaxRetry is the amount of retries. Negative number let retry forever
 	MaxRetry int
 	// RetryDelay is the amount of time * retry counter wait before the next try
 	WaitBetweenRetries time.Duration
+	// Backoff calculates the wait before the next try, replaces WaitBetweenRetries if set
+	Backoff Backoff
+	// MaxDelay caps the wait requested by the Retry-After header of the receiver, unlimited if zero
+	MaxDelay time.Duration
+	// Deadline is the maximum time spent on one message. Zero let retry without limit
+	Deadline time.Duration
+	// Clock used to wait, SystemClock if nil
+	Clock Clock
+	// StatusRules classifies failures, every failure is retried if nil
+	StatusRules StatusRules
 }
 
 // ConsumeMessage send the message to the given MessageHandler and retries if needed.
 func (r *RetryMessageHandler) ConsumeMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
 	glog.V(3).Infof("consume message of topic %s, partition %d and offset %d", msg.Topic, msg.Partition, msg.Offset)
+	return r.retry(ctx, func(ctx context.Context) error {
+		return r.MessageHandler.ConsumeMessage(ctx, msg)
+	})
+}
+
+// ConsumeMessages send the batch to the given BatchHandler and retries if needed.
+func (r *RetryMessageHandler) ConsumeMessages(ctx context.Context, msgs []*sarama.ConsumerMessage) error {
+	glog.V(3).Infof("consume batch of %d messages", len(msgs))
+	return r.retry(ctx, func(ctx context.Context) error {
+		return r.BatchHandler.ConsumeMessages(ctx, msgs)
+	})
+}
+
+func (r *RetryMessageHandler) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	clock := r.clock()
+	start := clock.Now()
+	var wait time.Duration
 	counter := 0
 	for {
 		counter++
-		err := r.MessageHandler.ConsumeMessage(ctx, msg)
+		err := fn(ctx)
 		if err == nil {
 			glog.V(3).Infof("consume message successful")
 			return nil
 		}
+		if circuitOpenError := circuitOpenErrorOf(err); circuitOpenError != nil {
+			// no request was sent, pause without counting an attempt or spending the deadline
+			counter--
+			glog.V(2).Infof("circuit of host %s open => pause %v", circuitOpenError.Host, circuitOpenError.RetryAfter)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-clock.After(circuitOpenError.RetryAfter):
+			}
+			start = start.Add(circuitOpenError.RetryAfter)
+			continue
+		}
+		classification := r.StatusRules.ClassifyError(err)
+		if classification == ClassificationPermanent {
+			glog.V(3).Infof("message handler returned permanent error => skip retry")
+			return &PermanentError{
+				Attempts: counter,
+				Err:      err,
+			}
+		}
 		glog.V(3).Infof("message handler returned error => retry")
 		if r.MaxRetry >= 0 && counter > r.MaxRetry {
-			return errors.Wrapf(err, "max retries reached")
+			return &MaxRetriesError{
+				Attempts: counter,
+				Err:      err,
+			}
+		}
+		wait = r.backoff().NextDelay(counter, wait)
+		if statusError := statusErrorOf(err); classification == ClassificationRetryAfter && statusError != nil && statusError.RetryAfter > 0 {
+			wait = statusError.RetryAfter
+			if r.MaxDelay > 0 && wait > r.MaxDelay {
+				wait = r.MaxDelay
+			}
+		}
+		if r.Deadline > 0 && clock.Now().Add(wait).Sub(start) > r.Deadline {
+			return &RetryDeadlineError{
+				Attempts: counter,
+				Err:      err,
+			}
 		}
-		wait := r.WaitBetweenRetries * time.Duration(counter)
 		glog.V(1).Infof("handle message failed %d times => retry in %v", counter, wait)
 		if wait > 0 {
 			select {
 			case <-ctx.Done():
 				return nil
-			case <-time.NewTimer(wait).C:
+			case <-clock.After(wait):
 				glog.V(3).Infof("wait for %v completed", wait)
 			}
 		}
 	}
 }
+
+func (r *RetryMessageHandler) backoff() Backoff {
+	if r.Backoff != nil {
+		return r.Backoff
+	}
+	return &LinearBackoff{Delay: r.WaitBetweenRetries}
+}
+
+func (r *RetryMessageHandler) clock() Clock {
+	if r.Clock != nil {
+		return r.Clock
+	}
+	return SystemClock{}
+}
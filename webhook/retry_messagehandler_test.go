@@ -60,4 +60,103 @@ var _ = Describe("RetryMessageHandler", func() {
 		Expect(err).NotTo(BeNil())
 		Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(retryMessageHandler.MaxRetry + 1))
 	})
+
+	It("returns attempts if max retries reached", func() {
+		retryMessageHandler.MaxRetry = 2
+		messageHandler.ConsumeMessageReturns(errors.New("banana"))
+
+		err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		maxRetriesError, ok := err.(*webhook.MaxRetriesError)
+		Expect(ok).To(BeTrue())
+		Expect(maxRetriesError.Attempts).To(Equal(3))
+	})
+
+	It("skips retries on permanent errors", func() {
+		retryMessageHandler.StatusRules = webhook.StatusRules{
+			{From: 400, To: 499, Classification: webhook.ClassificationPermanent},
+		}
+		messageHandler.ConsumeMessageReturns(&webhook.StatusError{StatusCode: 400})
+
+		err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		Expect(err).To(BeAssignableToTypeOf(&webhook.PermanentError{}))
+		Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(1))
+	})
+
+	It("waits for retry after", func() {
+		retryMessageHandler.WaitBetweenRetries = time.Hour
+		retryMessageHandler.StatusRules = webhook.StatusRules{
+			{From: 429, To: 429, Classification: webhook.ClassificationRetryAfter},
+		}
+		messageHandler.ConsumeMessageReturnsOnCall(0, &webhook.StatusError{StatusCode: 429, RetryAfter: time.Millisecond})
+		messageHandler.ConsumeMessageReturnsOnCall(1, nil)
+
+		err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+		Expect(err).To(BeNil())
+		Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(2))
+	})
+
+	Context("with clock", func() {
+		var clock *mocks.Clock
+		var now time.Time
+		var waits []time.Duration
+		BeforeEach(func() {
+			now = time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+			waits = nil
+			clock = &mocks.Clock{}
+			clock.NowStub = func() time.Time {
+				return now
+			}
+			clock.AfterStub = func(d time.Duration) <-chan time.Time {
+				waits = append(waits, d)
+				now = now.Add(d)
+				result := make(chan time.Time, 1)
+				result <- now
+				return result
+			}
+			retryMessageHandler.Clock = clock
+			retryMessageHandler.Backoff = &webhook.ExponentialBackoff{Delay: time.Second}
+			messageHandler.ConsumeMessageReturns(errors.New("banana"))
+		})
+		It("waits given by backoff", func() {
+			retryMessageHandler.MaxRetry = 4
+
+			err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+			Expect(err).To(BeAssignableToTypeOf(&webhook.MaxRetriesError{}))
+			Expect(waits).To(Equal([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}))
+		})
+		It("stops retry if deadline is reached", func() {
+			retryMessageHandler.Deadline = 10 * time.Second
+
+			err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+			Expect(err).To(BeAssignableToTypeOf(&webhook.RetryDeadlineError{}))
+			Expect(waits).To(Equal([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second}))
+			Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(4))
+		})
+		It("caps retry after by max delay", func() {
+			retryMessageHandler.MaxDelay = 5 * time.Second
+			retryMessageHandler.StatusRules = webhook.StatusRules{
+				{From: 429, To: 429, Classification: webhook.ClassificationRetryAfter},
+			}
+			messageHandler.ConsumeMessageReturnsOnCall(0, &webhook.StatusError{StatusCode: 429, RetryAfter: 24 * time.Hour})
+			messageHandler.ConsumeMessageReturnsOnCall(1, nil)
+
+			err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+			Expect(err).To(BeNil())
+			Expect(waits).To(Equal([]time.Duration{5 * time.Second}))
+		})
+		It("pauses while the circuit is open without counting attempts", func() {
+			retryMessageHandler.MaxRetry = 1
+			retryMessageHandler.Deadline = 10 * time.Second
+			circuitOpenError := errors.Wrap(&webhook.CircuitOpenError{Host: "example.com", RetryAfter: 30 * time.Second}, "perform request failed")
+			messageHandler.ConsumeMessageReturnsOnCall(0, circuitOpenError)
+			messageHandler.ConsumeMessageReturnsOnCall(1, circuitOpenError)
+			messageHandler.ConsumeMessageReturnsOnCall(2, errors.New("banana"))
+			messageHandler.ConsumeMessageReturnsOnCall(3, nil)
+
+			err := retryMessageHandler.ConsumeMessage(context.Background(), &sarama.ConsumerMessage{})
+			Expect(err).To(BeNil())
+			Expect(waits).To(Equal([]time.Duration{30 * time.Second, 30 * time.Second, time.Second}))
+			Expect(messageHandler.ConsumeMessageCallCount()).To(Equal(4))
+		})
+	})
 })
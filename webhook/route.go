@@ -0,0 +1,352 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	runerrors "github.com/bborbe/run/errors"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Route describes how the records of one kafka topic are delivered to one webhook.
+type Route struct {
+	Name                 string            `yaml:"name"`
+	KafkaTopic           string            `yaml:"kafka-topic"`
+	KafkaTopicPattern    string            `yaml:"kafka-topic-pattern"`
+	KafkaGroup           string            `yaml:"kafka-group"`
+	Filter               string            `yaml:"filter"`
+	HeaderAllow          string            `yaml:"header-allow"`
+	HeaderDeny           string            `yaml:"header-deny"`
+	HeaderPrefix         string            `yaml:"header-prefix"`
+	HookMethod           string            `yaml:"hook-method"`
+	HookURL              string            `yaml:"hook-url"`
+	HookAllowedHosts     string            `yaml:"hook-allowed-hosts"`
+	HookTimeout          time.Duration     `yaml:"hook-timeout"`
+	Encoding             string            `yaml:"encoding"`
+	ContentType          string            `yaml:"content-type"`
+	CloudEventsSource    string            `yaml:"cloudevents-source"`
+	CloudEventsType      string            `yaml:"cloudevents-type"`
+	TransformBody        string            `yaml:"transform-body"`
+	TransformHeaders     map[string]string `yaml:"transform-headers"`
+	RetryBackoff         string            `yaml:"retry-backoff"`
+	RetryDeadline        time.Duration     `yaml:"retry-deadline"`
+	RetryDelay           time.Duration     `yaml:"retry-delay"`
+	RetryLimit           int               `yaml:"retry-limit"`
+	RetryMaxDelay        time.Duration     `yaml:"retry-max-delay"`
+	StatusRules          string            `yaml:"status-rules"`
+	RateLimit            float64           `yaml:"rate-limit"`
+	RateLimitBurst       int               `yaml:"rate-limit-burst"`
+	RateLimitBytes       int               `yaml:"rate-limit-bytes"`
+	RateLimitScope       string            `yaml:"rate-limit-scope"`
+	StrictOrder          bool              `yaml:"strict-order"`
+	Workers              int               `yaml:"workers"`
+	StartPosition        string            `yaml:"start-position"`
+	ResetOffsets         bool              `yaml:"reset-offsets"`
+	BatchSize            int               `yaml:"batch-size"`
+	BatchBytes           int               `yaml:"batch-bytes"`
+	BatchLinger          time.Duration     `yaml:"batch-linger"`
+	BatchFormat          string            `yaml:"batch-format"`
+	Secret               string            `yaml:"secret"`
+	SecretPath           string            `yaml:"secret-path"`
+	SigningKey           string            `yaml:"signing-key"`
+	SigningKeyID         string            `yaml:"signing-key-id"`
+	SignatureVersion     int               `yaml:"signature-version"`
+	AuthType             string            `yaml:"auth-type"`
+	AuthTokenFile        string            `yaml:"auth-token-file"`
+	AuthUser             string            `yaml:"auth-user"`
+	AuthPasswordFile     string            `yaml:"auth-password-file"`
+	AuthHeaderFile       string            `yaml:"auth-header-file"`
+	AuthTokenURL         string            `yaml:"auth-token-url"`
+	AuthClientID         string            `yaml:"auth-client-id"`
+	AuthClientSecretFile string            `yaml:"auth-client-secret-file"`
+	AuthScopes           string            `yaml:"auth-scopes"`
+	DeadLetterTopic      string            `yaml:"dead-letter-topic"`
+	// Destinations deliver every record independently, each inherits all settings of the route
+	Destinations []Route `yaml:"-"`
+}
+
+// Validate returns all problems of the route at once.
+func (r *Route) Validate() error {
+	if errs := r.validate(); len(errs) > 0 {
+		return runerrors.New(errs...)
+	}
+	return nil
+}
+
+func (r *Route) validate() []error {
+	var errs []error
+	if r.Name == "" {
+		errs = append(errs, errors.New("route Name missing"))
+	}
+	if selector, err := NewTopicSelector(r.KafkaTopic, r.KafkaTopicPattern); err != nil {
+		errs = append(errs, r.errorf("KafkaTopicPattern invalid: %v", err))
+	} else if selector.Empty() {
+		errs = append(errs, r.errorf("KafkaTopic missing"))
+	} else if r.DeadLetterTopic != "" && selector.selects(r.DeadLetterTopic) {
+		// failed records would be consumed again and fail forever
+		errs = append(errs, r.errorf("DeadLetterTopic %s is consumed by the route", r.DeadLetterTopic))
+	}
+	if r.KafkaGroup == "" {
+		errs = append(errs, r.errorf("KafkaGroup missing"))
+	}
+	if r.HookURL == "" && len(r.Destinations) == 0 {
+		errs = append(errs, r.errorf("Url missing"))
+	}
+	if r.HookMethod == "" {
+		errs = append(errs, r.errorf("HookMethod missing"))
+	}
+	if r.HookTimeout <= 0 {
+		errs = append(errs, r.errorf("HookTimeout invalid"))
+	}
+	if r.Secret == "" && r.SecretPath == "" && r.SigningKey == "" {
+		errs = append(errs, r.errorf("Secret missing"))
+	}
+	if r.BatchSize < 0 || r.BatchBytes < 0 || r.BatchLinger < 0 {
+		errs = append(errs, r.errorf("Batch settings invalid"))
+	}
+	if !IsBatchFormat(r.BatchFormat) {
+		errs = append(errs, r.errorf("BatchFormat %s unknown", r.BatchFormat))
+	}
+	if r.BatchSize > 0 && r.Encoding != "" && r.Encoding != EncodingDefault {
+		errs = append(errs, r.errorf("Encoding %s does not support batches", r.Encoding))
+	}
+	if _, err := ParseFilter(r.Filter); err != nil {
+		errs = append(errs, r.errorf("Filter invalid: %v", err))
+	}
+	if transform, err := NewTransform(r.TransformBody, r.TransformHeaders); err != nil {
+		errs = append(errs, r.errorf("Transform invalid: %v", err))
+	} else if transform != nil && r.BatchSize > 0 {
+		errs = append(errs, r.errorf("Transform does not support batches"))
+	}
+	if IsURLTemplate(r.HookURL) {
+		if _, err := NewURLTemplate(r.HookURL, r.HookAllowedHosts); err != nil {
+			errs = append(errs, r.errorf("Url invalid: %v", err))
+		}
+		if r.BatchSize > 0 {
+			errs = append(errs, r.errorf("Url template does not support batches"))
+		}
+	}
+	if _, err := ParseStartPosition(r.StartPosition); err != nil {
+		errs = append(errs, r.errorf("StartPosition invalid: %v", err))
+	}
+	if r.RateLimit < 0 || r.RateLimitBurst < 0 || r.RateLimitBytes < 0 {
+		errs = append(errs, r.errorf("RateLimit settings invalid"))
+	}
+	if !IsRateLimitScope(r.RateLimitScope) {
+		errs = append(errs, r.errorf("RateLimitScope %s unknown", r.RateLimitScope))
+	}
+	for _, err := range r.authConfig().Validate() {
+		errs = append(errs, r.errorf("%v", err))
+	}
+	if r.Workers < 0 {
+		errs = append(errs, r.errorf("Workers invalid"))
+	}
+	if r.SignatureVersion != 0 && r.SignatureVersion != SignatureV1 && r.SignatureVersion != SignatureV2 {
+		errs = append(errs, r.errorf("SignatureVersion %d unknown", r.SignatureVersion))
+	}
+	if !IsEncoding(r.Encoding) {
+		errs = append(errs, r.errorf("Encoding %s unknown", r.Encoding))
+	}
+	if r.Encoding == EncodingStandardWebhooks && r.Secret == "" {
+		errs = append(errs, r.errorf("Encoding %s requires Secret", r.Encoding))
+	}
+	if _, err := NewBackoff(r.RetryBackoff, r.RetryDelay, r.RetryMaxDelay); err != nil {
+		errs = append(errs, r.errorf("RetryBackoff invalid: %v", err))
+	}
+	if _, err := ParseStatusRules(r.StatusRules); err != nil {
+		errs = append(errs, r.errorf("StatusRules invalid: %v", err))
+	}
+	for _, destination := range r.Destinations {
+		errs = append(errs, destination.validate()...)
+	}
+	return errs
+}
+
+// authConfig returns the authentication settings of the route.
+func (r *Route) authConfig() *AuthConfig {
+	return &AuthConfig{
+		Type:             r.AuthType,
+		TokenFile:        r.AuthTokenFile,
+		User:             r.AuthUser,
+		PasswordFile:     r.AuthPasswordFile,
+		HeaderFile:       r.AuthHeaderFile,
+		TokenURL:         r.AuthTokenURL,
+		ClientID:         r.AuthClientID,
+		ClientSecretFile: r.AuthClientSecretFile,
+		Scopes:           r.AuthScopes,
+	}
+}
+
+func (r *Route) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("route %s: %s", r.Name, fmt.Sprintf(format, args...))
+}
+
+// ReadRoutes reads the routes of the given YAML or JSON file.
+func ReadRoutes(path string, defaults Route) ([]Route, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read config %s failed", path)
+	}
+	routes, err := ParseRoutes(content, defaults)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse config %s failed", path)
+	}
+	return routes, nil
+}
+
+// ParseRoutes parses a list of routes. Every setting not defined by a route is taken from defaults,
+// except the kafka group, which defaults to "<group>-<route name>" so every route consumes all its records.
+// Routes sharing a kafka group must not select the same topics.
+//
+//	routes:
+//	- name: orders
+//	  kafka-topic: orders
+//	  kafka-group: orders-webhook
+//	  hook-url: http://orders.example.com/hook
+func ParseRoutes(content []byte, defaults Route) ([]Route, error) {
+	var config struct {
+		Routes []yaml.MapSlice `yaml:"routes"`
+	}
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, errors.Wrap(err, "unmarshal config failed")
+	}
+	if len(config.Routes) == 0 {
+		return nil, errors.New("no routes defined")
+	}
+	names := make(map[string]bool)
+	routes := make([]Route, 0, len(config.Routes))
+	for i, values := range config.Routes {
+		var destinations []yaml.MapSlice
+		values, err := extractDestinations(values, &destinations)
+		if err != nil {
+			return nil, errors.Wrapf(err, "route %d invalid", i)
+		}
+		route, err := parseRoute(values, defaults)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unmarshal route %d failed", i)
+		}
+		if route.Name == "" {
+			return nil, errors.Errorf("name of route %d missing", i)
+		}
+		if names[route.Name] {
+			return nil, errors.Errorf("route name %s is not unique", route.Name)
+		}
+		names[route.Name] = true
+		if !hasKey(values, "kafka-group") && defaults.KafkaGroup != "" {
+			route.KafkaGroup = defaults.KafkaGroup + "-" + route.Name
+		}
+		destinationNames := make(map[string]bool)
+		for j, values := range destinations {
+			for _, item := range values {
+				if key, _ := item.Key.(string); routeOnlyFields[key] {
+					return nil, errors.Errorf("destination %d of route %s must not define %s", j, route.Name, key)
+				}
+			}
+			destination, err := parseRoute(values, route)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unmarshal destination %d of route %s failed", j, route.Name)
+			}
+			if destination.Name == "" {
+				return nil, errors.Errorf("name of destination %d of route %s missing", j, route.Name)
+			}
+			if destinationNames[destination.Name] {
+				return nil, errors.Errorf("destination name %s of route %s is not unique", destination.Name, route.Name)
+			}
+			destinationNames[destination.Name] = true
+			destination.Name = route.Name + "/" + destination.Name
+			route.Destinations = append(route.Destinations, destination)
+		}
+		routes = append(routes, route)
+	}
+	if err := checkGroups(routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// checkGroups returns an error if routes of the same kafka group select the same topics,
+// they would split the partitions and each deliver only a part of the records.
+func checkGroups(routes []Route) error {
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			if routes[i].KafkaGroup == "" || routes[i].KafkaGroup != routes[j].KafkaGroup {
+				continue
+			}
+			a, err := NewTopicSelector(routes[i].KafkaTopic, routes[i].KafkaTopicPattern)
+			if err != nil {
+				continue
+			}
+			b, err := NewTopicSelector(routes[j].KafkaTopic, routes[j].KafkaTopicPattern)
+			if err != nil {
+				continue
+			}
+			if a.Overlaps(b) {
+				return errors.Errorf("routes %s and %s share kafka group %s and select the same topics", routes[i].Name, routes[j].Name, routes[i].KafkaGroup)
+			}
+		}
+	}
+	return nil
+}
+
+func hasKey(values yaml.MapSlice, key string) bool {
+	for _, item := range values {
+		if k, _ := item.Key.(string); k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// routeOnlyFields select the records of a route and can not differ between its destinations.
+var routeOnlyFields = map[string]bool{
+	"kafka-topic":         true,
+	"kafka-topic-pattern": true,
+	"kafka-group":         true,
+	"start-position":      true,
+	"reset-offsets":       true,
+}
+
+// extractDestinations returns the values without destinations and stores the destinations.
+func extractDestinations(values yaml.MapSlice, destinations *[]yaml.MapSlice) (yaml.MapSlice, error) {
+	result := make(yaml.MapSlice, 0, len(values))
+	for _, item := range values {
+		if key, _ := item.Key.(string); key != "destinations" {
+			result = append(result, item)
+			continue
+		}
+		content, err := yaml.Marshal(item.Value)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal destinations failed")
+		}
+		if err := yaml.UnmarshalStrict(content, destinations); err != nil {
+			return nil, errors.Wrap(err, "unmarshal destinations failed")
+		}
+	}
+	return result, nil
+}
+
+// parseRoute returns defaults with all settings of values applied.
+func parseRoute(values yaml.MapSlice, defaults Route) (Route, error) {
+	route := defaults
+	route.Name = ""
+	route.Destinations = nil
+	// maps are merged by yaml, headers of a route replace the default headers instead
+	route.TransformHeaders = nil
+	content, err := yaml.Marshal(values)
+	if err != nil {
+		return Route{}, errors.Wrap(err, "marshal failed")
+	}
+	if err := yaml.UnmarshalStrict(content, &route); err != nil {
+		return Route{}, err
+	}
+	if route.TransformHeaders == nil {
+		route.TransformHeaders = defaults.TransformHeaders
+	}
+	return route, nil
+}
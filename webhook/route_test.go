@@ -0,0 +1,276 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route", func() {
+	var defaults webhook.Route
+	BeforeEach(func() {
+		defaults = webhook.Route{
+			Name:        "default",
+			KafkaGroup:  "my-group",
+			HookMethod:  http.MethodPost,
+			HookTimeout: 10 * time.Second,
+			RetryDelay:  time.Second,
+			RetryLimit:  -1,
+			Secret:      "secret",
+		}
+	})
+	It("parses yaml", func() {
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  kafka-topic: orders
+  hook-url: http://orders.example.com
+  hook-method: PUT
+  hook-timeout: 3s
+  retry-limit: 5
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes).To(HaveLen(1))
+		Expect(routes[0]).To(Equal(webhook.Route{
+			Name:        "orders",
+			KafkaTopic:  "orders",
+			KafkaGroup:  "my-group-orders",
+			HookMethod:  http.MethodPut,
+			HookURL:     "http://orders.example.com",
+			HookTimeout: 3 * time.Second,
+			RetryDelay:  time.Second,
+			RetryLimit:  5,
+			Secret:      "secret",
+		}))
+	})
+	It("parses json", func() {
+		routes, err := webhook.ParseRoutes([]byte(`{"routes":[{"name":"a","kafka-topic":"a"},{"name":"b","kafka-topic":"b"}]}`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes).To(HaveLen(2))
+		Expect(routes[0].KafkaTopic).To(Equal("a"))
+		Expect(routes[1].KafkaTopic).To(Equal("b"))
+		Expect(routes[1].Secret).To(Equal("secret"))
+	})
+	It("returns error for unknown fields", func() {
+		_, err := webhook.ParseRoutes([]byte(`{"routes":[{"name":"a","kafka-topik":"a"}]}`), defaults)
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns error if name is missing", func() {
+		_, err := webhook.ParseRoutes([]byte(`{"routes":[{"kafka-topic":"a"}]}`), defaults)
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns error if name is not unique", func() {
+		_, err := webhook.ParseRoutes([]byte(`{"routes":[{"name":"a"},{"name":"a"}]}`), defaults)
+		Expect(err).To(HaveOccurred())
+	})
+	It("gives every route its own default kafka group", func() {
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: a
+  kafka-topic: orders
+- name: b
+  kafka-topic: orders
+- name: c
+  kafka-topic: orders
+  kafka-group: shared
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes[0].KafkaGroup).To(Equal("my-group-a"))
+		Expect(routes[1].KafkaGroup).To(Equal("my-group-b"))
+		Expect(routes[2].KafkaGroup).To(Equal("shared"))
+	})
+	It("returns error for routes sharing a kafka group and topics", func() {
+		for _, topics := range [][2]string{
+			{"kafka-topic: orders", "kafka-topic: invoices,orders"},
+			{"kafka-topic: orders", "kafka-topic-pattern: ord.*"},
+			{"kafka-topic-pattern: a.*", "kafka-topic-pattern: b.*"},
+		} {
+			_, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: a
+  kafka-group: shared
+  `+topics[0]+`
+- name: b
+  kafka-group: shared
+  `+topics[1]+`
+`), defaults)
+			Expect(err).NotTo(BeNil(), "%v", topics)
+		}
+	})
+	It("accepts routes sharing a kafka group with distinct topics", func() {
+		_, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: a
+  kafka-group: shared
+  kafka-topic: orders
+- name: b
+  kafka-group: shared
+  kafka-topic-pattern: inv.*
+`), defaults)
+		Expect(err).To(BeNil())
+	})
+	It("returns error if the dead letter topic is consumed by the route", func() {
+		for _, topics := range []string{"kafka-topic: orders,orders-failed", "kafka-topic-pattern: orders.*"} {
+			routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  hook-url: http://orders.example.com
+  dead-letter-topic: orders-failed
+  `+topics+`
+`), defaults)
+			Expect(err).To(BeNil())
+			Expect(routes[0].Validate()).NotTo(BeNil(), topics)
+		}
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  hook-url: http://orders.example.com
+  dead-letter-topic: orders-failed
+  kafka-topic: orders
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes[0].Validate()).To(BeNil())
+	})
+	It("returns error if the dead letter topic of a destination is consumed by the route", func() {
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  kafka-topic-pattern: orders.*
+  destinations:
+  - name: crm
+    hook-url: http://crm.example.com
+    dead-letter-topic: orders-crm-failed
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes[0].Validate()).NotTo(BeNil())
+	})
+	It("returns error if no routes defined", func() {
+		_, err := webhook.ParseRoutes([]byte(`routes: []`), defaults)
+		Expect(err).To(HaveOccurred())
+	})
+	It("validates all fields at once", func() {
+		route := webhook.Route{Name: "orders"}
+		err := route.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("route orders: KafkaTopic missing"))
+		Expect(err.Error()).To(ContainSubstring("route orders: Secret missing"))
+	})
+	It("returns error for unknown encoding", func() {
+		route := defaults
+		route.KafkaTopic = "orders"
+		route.HookURL = "http://orders.example.com"
+		route.Encoding = "soap"
+		Expect(route.Validate()).To(HaveOccurred())
+		route.Encoding = webhook.EncodingCloudEventsBinary
+		Expect(route.Validate()).To(BeNil())
+	})
+	It("requires secret for standard webhooks", func() {
+		route := defaults
+		route.KafkaTopic = "orders"
+		route.HookURL = "http://orders.example.com"
+		route.Encoding = webhook.EncodingStandardWebhooks
+		route.Secret = ""
+		route.SecretPath = "/keys"
+		Expect(route.Validate()).To(HaveOccurred())
+	})
+	It("accepts a topic pattern instead of a topic", func() {
+		route := defaults
+		route.KafkaTopicPattern = `orders\..*`
+		route.HookURL = "http://orders.example.com"
+		Expect(route.Validate()).To(BeNil())
+		route.KafkaTopicPattern = "orders("
+		Expect(route.Validate()).To(HaveOccurred())
+	})
+	It("replaces default transform headers", func() {
+		defaults.TransformHeaders = map[string]string{"X-Source": "kafka"}
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: a
+  kafka-topic: a
+  transform-headers:
+    X-Event: "{{ .Topic }}"
+- name: b
+  kafka-topic: b
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes[0].TransformHeaders).To(Equal(map[string]string{"X-Event": "{{ .Topic }}"}))
+		Expect(routes[1].TransformHeaders).To(Equal(map[string]string{"X-Source": "kafka"}))
+		Expect(defaults.TransformHeaders).To(Equal(map[string]string{"X-Source": "kafka"}))
+	})
+	It("returns error for transform with batches", func() {
+		route := defaults
+		route.KafkaTopic = "orders"
+		route.HookURL = "http://orders.example.com"
+		route.TransformBody = "{{ .Value }}"
+		Expect(route.Validate()).To(BeNil())
+		route.BatchSize = 10
+		Expect(route.Validate()).To(HaveOccurred())
+		route.BatchSize = 0
+		route.TransformBody = "{{ .Value"
+		Expect(route.Validate()).To(HaveOccurred())
+	})
+	It("parses destinations inheriting the route settings", func() {
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  kafka-topic: orders
+  retry-limit: 5
+  destinations:
+  - name: crm
+    hook-url: http://crm.example.com
+  - name: billing
+    hook-url: http://billing.example.com
+    retry-limit: 1
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes).To(HaveLen(1))
+		Expect(routes[0].Validate()).To(BeNil())
+		Expect(routes[0].Destinations).To(HaveLen(2))
+		Expect(routes[0].Destinations[0].Name).To(Equal("orders/crm"))
+		Expect(routes[0].Destinations[0].KafkaTopic).To(Equal("orders"))
+		Expect(routes[0].Destinations[0].RetryLimit).To(Equal(5))
+		Expect(routes[0].Destinations[1].Name).To(Equal("orders/billing"))
+		Expect(routes[0].Destinations[1].HookURL).To(Equal("http://billing.example.com"))
+		Expect(routes[0].Destinations[1].RetryLimit).To(Equal(1))
+	})
+	It("returns error for destinations selecting records", func() {
+		_, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  kafka-topic: orders
+  destinations:
+  - name: crm
+    kafka-topic: customers
+`), defaults)
+		Expect(err).NotTo(BeNil())
+	})
+	It("returns error for duplicate destination names", func() {
+		_, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  kafka-topic: orders
+  destinations:
+  - name: crm
+  - name: crm
+`), defaults)
+		Expect(err).NotTo(BeNil())
+	})
+	It("validates destinations", func() {
+		routes, err := webhook.ParseRoutes([]byte(`
+routes:
+- name: orders
+  kafka-topic: orders
+  destinations:
+  - name: crm
+`), defaults)
+		Expect(err).To(BeNil())
+		Expect(routes[0].Validate()).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,48 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"github.com/xdg/scram"
+)
+
+// scramClient implements the SCRAM conversation of sarama for one connection.
+type scramClient struct {
+	HashGeneratorFcn scram.HashGeneratorFcn
+
+	conversation *scram.ClientConversation
+}
+
+// newScramClientGenerator returns a sarama.Config.Net.SASL.SCRAMClientGeneratorFunc for the hash.
+func newScramClientGenerator(hashGeneratorFcn scram.HashGeneratorFcn) func() sarama.SCRAMClient {
+	return func() sarama.SCRAMClient {
+		return &scramClient{
+			HashGeneratorFcn: hashGeneratorFcn,
+		}
+	}
+}
+
+func (s *scramClient) Begin(userName, password, authzID string) error {
+	client, err := s.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return errors.Wrap(err, "create scram client failed")
+	}
+	s.conversation = client.NewConversation()
+	return nil
+}
+
+func (s *scramClient) Step(challenge string) (string, error) {
+	response, err := s.conversation.Step(challenge)
+	if err != nil {
+		return "", errors.Wrap(err, "scram step failed")
+	}
+	return response, nil
+}
+
+func (s *scramClient) Done() bool {
+	return s.conversation.Done()
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Signature schemes
+const (
+	// SignatureV1 signs the body
+	SignatureV1 = 1
+	// SignatureV2 signs timestamp, delivery id and body to prevent replays
+	SignatureV2 = 2
+)
+
+// DefaultTolerance is the maximum age of a request signed with SignatureV2.
+const DefaultTolerance = 5 * time.Minute
+
+// signature adds the signature headers to requests and verifies them.
+type signature struct {
+	Signer RequestSigner
+	// Version selects the signature scheme, SignatureV1 if zero
+	Version int
+	// Tolerance is the maximum age of a request with SignatureV2, DefaultTolerance if zero
+	Tolerance time.Duration
+	// Clock used for signature timestamps, SystemClock if nil
+	Clock Clock
+}
+
+// sign adds the signature headers. The delivery id is only sent with SignatureV2.
+func (s signature) sign(header http.Header, deliveryID string, content []byte) error {
+	switch s.version() {
+	case SignatureV1:
+		header.Add(SignaturField, s.Signer.Sign(content))
+		return nil
+	case SignatureV2:
+		timestamp := strconv.FormatInt(s.clock().Now().Unix(), 10)
+		header.Add(SignatureVersionField, strconv.Itoa(SignatureV2))
+		header.Add(SignatureTimestampField, timestamp)
+		header.Add(DeliveryIdField, deliveryID)
+		header.Add(SignaturField, s.Signer.Sign(signaturePayloadV2(timestamp, deliveryID, content)))
+		return nil
+	default:
+		return errors.Errorf("unknown signature version %d", s.Version)
+	}
+}
+
+func (s signature) verify(header http.Header, content []byte) error {
+	switch s.version() {
+	case SignatureV1:
+	case SignatureV2:
+		if header.Get(SignatureVersionField) != strconv.Itoa(SignatureV2) {
+			return fmt.Errorf("signature version %d required", SignatureV2)
+		}
+		timestamp := header.Get(SignatureTimestampField)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "decode signature timestamp failed")
+		}
+		age := s.clock().Now().Sub(time.Unix(seconds, 0))
+		if age > s.tolerance() || age < -s.tolerance() {
+			return fmt.Errorf("signature timestamp outside tolerance of %v", s.tolerance())
+		}
+		deliveryID := header.Get(DeliveryIdField)
+		if deliveryID == "" {
+			return fmt.Errorf("delivery id missing")
+		}
+		content = signaturePayloadV2(timestamp, deliveryID, content)
+	default:
+		return errors.Errorf("unknown signature version %d", s.Version)
+	}
+	equal, err := s.Signer.Compare(content, header.Get(SignaturField))
+	if err != nil {
+		return fmt.Errorf("compare signs failed")
+	}
+	if !equal {
+		return fmt.Errorf("check secret failed")
+	}
+	return nil
+}
+
+func (s signature) version() int {
+	if s.Version == 0 {
+		return SignatureV1
+	}
+	return s.Version
+}
+
+func (s signature) tolerance() time.Duration {
+	if s.Tolerance == 0 {
+		return DefaultTolerance
+	}
+	return s.Tolerance
+}
+
+func (s signature) clock() Clock {
+	if s.Clock != nil {
+		return s.Clock
+	}
+	return SystemClock{}
+}
+
+// signaturePayloadV2 returns "<timestamp>.<delivery id>.<body>".
+func signaturePayloadV2(timestamp string, deliveryID string, content []byte) []byte {
+	payload := make([]byte, 0, len(timestamp)+len(deliveryID)+len(content)+2)
+	payload = append(payload, timestamp...)
+	payload = append(payload, '.')
+	payload = append(payload, deliveryID...)
+	payload = append(payload, '.')
+	return append(payload, content...)
+}
+
+// DeliveryID returns "<topic>-<partition>-<offset>", which is unique per record and stable across retries.
+// It is sent as X-Delivery-Id with SignatureV2 and as id of CloudEvents and Standard Webhooks,
+// so receivers can drop duplicate deliveries.
+func DeliveryID(msg *sarama.ConsumerMessage) string {
+	return fmt.Sprintf("%s-%d-%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// batchDeliveryID returns "<topic>-<partition>-<first offset>-<last offset>" of the records of one partition.
+func batchDeliveryID(msgs []*sarama.ConsumerMessage) string {
+	if len(msgs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", DeliveryID(msgs[0]), msgs[len(msgs)-1].Offset)
+}
+
+// verifyDeliveryID returns an error if the signed delivery id does not belong to the decoded record,
+// the record fields in the headers are not signed themselves.
+func verifyDeliveryID(header http.Header, msg *sarama.ConsumerMessage) error {
+	if deliveryID := header.Get(DeliveryIdField); deliveryID != "" && deliveryID != DeliveryID(msg) {
+		return &SignatureError{Err: errors.Errorf("delivery id %s does not match record %s", deliveryID, DeliveryID(msg))}
+	}
+	return nil
+}
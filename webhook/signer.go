@@ -12,6 +12,12 @@ import (
 	"github.com/pkg/errors"
 )
 
+// RequestSigner signs the content of requests and verifies the signature of received requests.
+type RequestSigner interface {
+	Compare(content []byte, sign string) (bool, error)
+	Sign(content []byte) string
+}
+
 type Signer struct {
 	Secret string
 }
@@ -30,7 +36,11 @@ func (r *Signer) Sign(content []byte) string {
 }
 
 func (r *Signer) signBody(content []byte) []byte {
-	computed := hmac.New(sha256.New, []byte(r.Secret))
+	return hmacSha256(r.Secret, content)
+}
+
+func hmacSha256(secret string, content []byte) []byte {
+	computed := hmac.New(sha256.New, []byte(secret))
 	computed.Write(content)
 	return []byte(computed.Sum(nil))
 }
@@ -0,0 +1,169 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Headers of the Standard Webhooks spec
+const (
+	StandardWebhooksIdField        = "Webhook-Id"
+	StandardWebhooksTimestampField = "Webhook-Timestamp"
+	StandardWebhooksSignatureField = "Webhook-Signature"
+)
+
+// standardWebhooksSecretPrefix marks base64 encoded secrets.
+const standardWebhooksSecretPrefix = "whsec_"
+
+// StandardWebhooksCoding sends records as specified by Standard Webhooks.
+// The record value is the body, key, topic, partition, offset and timestamps are sent as X-Message-* headers.
+type StandardWebhooksCoding struct {
+	Url    string
+	Method string
+	// ContentType of the record values, application/octet-stream if empty
+	ContentType string
+	// Secret used for the HMAC signature, base64 encoded if prefixed with whsec_
+	Secret string
+	// HeaderPrefix is prepended to the keys of record headers sent as HTTP headers, record headers are dropped if empty
+	HeaderPrefix string
+	// HeaderFilter selects the record headers to send, all if nil
+	HeaderFilter *HeaderFilter
+	// Tolerance is the maximum age of a request, DefaultTolerance if zero
+	Tolerance time.Duration
+	// Clock used for signature timestamps, SystemClock if nil
+	Clock Clock
+}
+
+func (s *StandardWebhooksCoding) Encode(msg *sarama.ConsumerMessage) (*http.Request, error) {
+	secret, err := s.secret()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(s.Method, s.Url, bytes.NewBuffer(msg.Value))
+	if err != nil {
+		return nil, errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Content-Type", contentTypeOrDefault(s.ContentType))
+	encodeMessageFields(req.Header, msg)
+	if s.HeaderPrefix != "" {
+		encodeRecordHeaders(req.Header, s.HeaderPrefix, s.HeaderFilter, msg.Headers)
+	}
+	id := DeliveryID(msg)
+	timestamp := strconv.FormatInt(s.clock().Now().Unix(), 10)
+	req.Header.Set(StandardWebhooksIdField, id)
+	req.Header.Set(StandardWebhooksTimestampField, timestamp)
+	req.Header.Set(StandardWebhooksSignatureField, "v1,"+base64.StdEncoding.EncodeToString(standardWebhooksSignature(secret, id, timestamp, msg.Value)))
+	return req, nil
+}
+
+func (s *StandardWebhooksCoding) Decode(req *http.Request) (*sarama.ConsumerMessage, error) {
+	content, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body failed")
+	}
+	defer req.Body.Close()
+	if err := s.verify(req.Header, content); err != nil {
+		return nil, &SignatureError{Err: err}
+	}
+	msg, err := decodeMessageFields(req.Header)
+	if err != nil {
+		return nil, err
+	}
+	if id := req.Header.Get(StandardWebhooksIdField); id != DeliveryID(msg) {
+		return nil, &SignatureError{Err: errors.Errorf("webhook id %s does not match record %s", id, DeliveryID(msg))}
+	}
+	msg.Value = content
+	if s.HeaderPrefix != "" {
+		if msg.Headers, err = decodeRecordHeaders(req.Header, s.HeaderPrefix); err != nil {
+			return nil, errors.Wrap(err, "decode headers failed")
+		}
+	}
+	return msg, nil
+}
+
+// verify checks the timestamp and accepts the request if one of the space separated v1 signatures matches.
+func (s *StandardWebhooksCoding) verify(header http.Header, content []byte) error {
+	secret, err := s.secret()
+	if err != nil {
+		return err
+	}
+	id := header.Get(StandardWebhooksIdField)
+	if id == "" {
+		return fmt.Errorf("webhook id missing")
+	}
+	timestamp := header.Get(StandardWebhooksTimestampField)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "decode webhook timestamp failed")
+	}
+	age := s.clock().Now().Sub(time.Unix(seconds, 0))
+	if age > s.tolerance() || age < -s.tolerance() {
+		return fmt.Errorf("webhook timestamp outside tolerance of %v", s.tolerance())
+	}
+	expected := standardWebhooksSignature(secret, id, timestamp, content)
+	for _, value := range strings.Fields(header.Get(StandardWebhooksSignatureField)) {
+		parts := strings.SplitN(value, ",", 2)
+		if len(parts) != 2 || parts[0] != "v1" {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("check secret failed")
+}
+
+func (s *StandardWebhooksCoding) secret() ([]byte, error) {
+	if !strings.HasPrefix(s.Secret, standardWebhooksSecretPrefix) {
+		return []byte(s.Secret), nil
+	}
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s.Secret, standardWebhooksSecretPrefix))
+	if err != nil {
+		return nil, errors.Wrap(err, "decode secret failed")
+	}
+	return secret, nil
+}
+
+func (s *StandardWebhooksCoding) tolerance() time.Duration {
+	if s.Tolerance == 0 {
+		return DefaultTolerance
+	}
+	return s.Tolerance
+}
+
+func (s *StandardWebhooksCoding) clock() Clock {
+	if s.Clock != nil {
+		return s.Clock
+	}
+	return SystemClock{}
+}
+
+// standardWebhooksSignature returns the HMAC-SHA256 of "<id>.<timestamp>.<body>".
+func standardWebhooksSignature(secret []byte, id string, timestamp string, content []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(content)
+	return mac.Sum(nil)
+}
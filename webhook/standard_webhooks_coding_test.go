@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/mocks"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StandardWebhooksCoding", func() {
+	var coding *webhook.StandardWebhooksCoding
+	var clock *mocks.Clock
+	var msg *sarama.ConsumerMessage
+	BeforeEach(func() {
+		clock = &mocks.Clock{}
+		clock.NowReturns(time.Unix(1614265330, 0))
+		coding = &webhook.StandardWebhooksCoding{
+			Url:    "http://example.com/hook",
+			Method: http.MethodPost,
+			Secret: "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw",
+			Clock:  clock,
+		}
+		msg = &sarama.ConsumerMessage{
+			Topic:     "orders",
+			Partition: 2,
+			Offset:    42,
+			Key:       []byte("key"),
+			Value:     []byte(`{"test": 2432232314}`),
+		}
+	})
+	It("sets webhook headers", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		Expect(req.Header.Get("webhook-id")).To(Equal("orders-2-42"))
+		Expect(req.Header.Get("webhook-timestamp")).To(Equal("1614265330"))
+		Expect(req.Header.Get("webhook-signature")).To(HavePrefix("v1,"))
+	})
+	It("encodes sarama message to request and back", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		message, err := coding.Decode(req)
+		Expect(err).To(BeNil())
+		Expect(message).To(Equal(msg))
+	})
+	It("verifies signature of the spec and rejects the id not matching the record", func() {
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/hook", bytes.NewBufferString(`{"test": 2432232314}`))
+		Expect(err).To(BeNil())
+		req.Header.Set("webhook-id", "msg_p5jXN8AQM9LWM0D4loKWxJek")
+		req.Header.Set("webhook-timestamp", "1614265330")
+		req.Header.Set("webhook-signature", "v1,invalid v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=")
+		req.Header.Set(webhook.TopicField, "orders")
+		req.Header.Set(webhook.PartitionField, "2")
+		req.Header.Set(webhook.OffsetField, "42")
+		_, err = coding.Decode(req)
+		Expect(err).To(MatchError("verify signature failed: webhook id msg_p5jXN8AQM9LWM0D4loKWxJek does not match record orders-2-42"))
+	})
+	It("rejects requests with modified offset", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		req.Header.Set(webhook.OffsetField, "999")
+		_, err = coding.Decode(req)
+		Expect(err).To(BeAssignableToTypeOf(&webhook.SignatureError{}))
+	})
+	It("rejects requests with modified topic", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		req.Header.Set(webhook.TopicField, "payments")
+		_, err = coding.Decode(req)
+		Expect(err).To(BeAssignableToTypeOf(&webhook.SignatureError{}))
+	})
+	It("rejects requests with modified partition", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		req.Header.Set(webhook.PartitionField, "3")
+		_, err = coding.Decode(req)
+		Expect(err).To(BeAssignableToTypeOf(&webhook.SignatureError{}))
+	})
+	It("rejects requests with modified webhook id", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		req.Header.Set("webhook-id", "orders-2-999")
+		_, err = coding.Decode(req)
+		Expect(err).To(BeAssignableToTypeOf(&webhook.SignatureError{}))
+	})
+	It("returns error if signature is invalid", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		coding.Secret = "other"
+		_, err = coding.Decode(req)
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns error if timestamp is too old", func() {
+		req, err := coding.Encode(msg)
+		Expect(err).To(BeNil())
+		clock.NowReturns(time.Unix(1614265330, 0).Add(time.Hour))
+		_, err = coding.Decode(req)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Start positions of consumer groups without committed offsets
+const (
+	StartOldest = "oldest"
+	StartNewest = "newest"
+	// startTimestampPrefix is followed by a RFC3339 time
+	startTimestampPrefix = "timestamp="
+)
+
+// StartPosition defines where a consumer group starts to read partitions without committed offset.
+type StartPosition struct {
+	// Initial is sarama.OffsetOldest or sarama.OffsetNewest, used for partitions without Time or explicit offset
+	Initial int64
+	// Time selects the first record at or after it if not zero
+	Time time.Time
+	// Offsets to start at by partition
+	Offsets map[int32]int64
+}
+
+// ParseStartPosition parses oldest (default), newest, timestamp=<RFC3339> or a comma separated list of partition:offset pairs.
+// Partitions missing in the list start at the oldest offset.
+func ParseStartPosition(value string) (*StartPosition, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "" || value == StartOldest:
+		return &StartPosition{Initial: sarama.OffsetOldest}, nil
+	case value == StartNewest:
+		return &StartPosition{Initial: sarama.OffsetNewest}, nil
+	case strings.HasPrefix(value, startTimestampPrefix):
+		t, err := time.Parse(time.RFC3339, strings.TrimPrefix(value, startTimestampPrefix))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse start position %s failed", value)
+		}
+		return &StartPosition{Initial: sarama.OffsetNewest, Time: t}, nil
+	}
+	position := &StartPosition{
+		Initial: sarama.OffsetOldest,
+		Offsets: make(map[int32]int64),
+	}
+	for _, pair := range splitList(value) {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("start position %s is not partition:offset", pair)
+		}
+		partition, err := strconv.ParseInt(parts[0], 10, 32)
+		if err != nil || partition < 0 {
+			return nil, errors.Errorf("partition of start position %s invalid", pair)
+		}
+		offset, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || offset < 0 {
+			return nil, errors.Errorf("offset of start position %s invalid", pair)
+		}
+		position.Offsets[int32(partition)] = offset
+	}
+	return position, nil
+}
+
+// Explicit returns true if the position can not be expressed by sarama's Consumer.Offsets.Initial.
+func (s *StartPosition) Explicit() bool {
+	return !s.Time.IsZero() || len(s.Offsets) > 0
+}
+
+// Resolve returns the offset to start the given partition at. getOffset is sarama.Client.GetOffset.
+func (s *StartPosition) Resolve(topic string, partition int32, getOffset func(topic string, partition int32, time int64) (int64, error)) (int64, error) {
+	if offset, ok := s.Offsets[partition]; ok {
+		return offset, nil
+	}
+	if !s.Time.IsZero() {
+		offset, err := getOffset(topic, partition, s.Time.UnixNano()/int64(time.Millisecond))
+		if err != nil {
+			return 0, errors.Wrapf(err, "get offset of topic %s partition %d at %v failed", topic, partition, s.Time)
+		}
+		// -1 if no record is at or after the time
+		if offset >= 0 {
+			return offset, nil
+		}
+	}
+	offset, err := getOffset(topic, partition, s.Initial)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get offset of topic %s partition %d failed", topic, partition)
+	}
+	return offset, nil
+}
+
+//go:generate counterfeiter -o ../mocks/offset_source.go --fake-name OffsetSource . OffsetSource
+
+// OffsetSource looks up offsets of partitions.
+type OffsetSource interface {
+	// GetOffset returns the first offset at or after time in milliseconds, or the offset of sarama.OffsetOldest and sarama.OffsetNewest
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+	// CommittedOffsets returns the offsets committed by the group, -1 for partitions without commit
+	CommittedOffsets(group string, topic string, partitions []int32) (map[int32]int64, error)
+}
+
+// ClientOffsetSource looks up offsets with a sarama client.
+type ClientOffsetSource struct {
+	Client sarama.Client
+}
+
+func (c *ClientOffsetSource) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	return c.Client.GetOffset(topic, partition, time)
+}
+
+func (c *ClientOffsetSource) CommittedOffsets(group string, topic string, partitions []int32) (map[int32]int64, error) {
+	coordinator, err := c.Client.Coordinator(group)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get coordinator of group %s failed", group)
+	}
+	request := &sarama.OffsetFetchRequest{
+		ConsumerGroup: group,
+		Version:       1,
+	}
+	for _, partition := range partitions {
+		request.AddPartition(topic, partition)
+	}
+	response, err := coordinator.FetchOffset(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch offsets of group %s failed", group)
+	}
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		block := response.GetBlock(topic, partition)
+		if block == nil {
+			return nil, errors.Errorf("offset of topic %s partition %d missing", topic, partition)
+		}
+		if block.Err != sarama.ErrNoError {
+			return nil, errors.Wrapf(block.Err, "fetch offset of topic %s partition %d failed", topic, partition)
+		}
+		offsets[partition] = block.Offset
+	}
+	return offsets, nil
+}
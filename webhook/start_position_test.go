@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StartPosition", func() {
+	Context("ParseStartPosition", func() {
+		It("defaults to oldest", func() {
+			position, err := webhook.ParseStartPosition("")
+			Expect(err).To(BeNil())
+			Expect(position.Initial).To(Equal(sarama.OffsetOldest))
+			Expect(position.Explicit()).To(BeFalse())
+		})
+		It("parses newest", func() {
+			position, err := webhook.ParseStartPosition("newest")
+			Expect(err).To(BeNil())
+			Expect(position.Initial).To(Equal(sarama.OffsetNewest))
+			Expect(position.Explicit()).To(BeFalse())
+		})
+		It("parses timestamp", func() {
+			position, err := webhook.ParseStartPosition("timestamp=2018-10-01T12:00:00Z")
+			Expect(err).To(BeNil())
+			Expect(position.Time).To(Equal(time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)))
+			Expect(position.Explicit()).To(BeTrue())
+		})
+		It("parses partition offsets", func() {
+			position, err := webhook.ParseStartPosition("0:10, 2:20")
+			Expect(err).To(BeNil())
+			Expect(position.Offsets).To(Equal(map[int32]int64{0: 10, 2: 20}))
+			Expect(position.Initial).To(Equal(sarama.OffsetOldest))
+			Expect(position.Explicit()).To(BeTrue())
+		})
+		It("returns error for invalid values", func() {
+			for _, value := range []string{"latest", "timestamp=yesterday", "0:", "a:1", "0:-1", "1:2:3"} {
+				_, err := webhook.ParseStartPosition(value)
+				Expect(err).NotTo(BeNil(), value)
+			}
+		})
+	})
+	Context("Resolve", func() {
+		var requested []int64
+		var offset int64
+		getOffset := func(topic string, partition int32, time int64) (int64, error) {
+			requested = append(requested, time)
+			return offset, nil
+		}
+		BeforeEach(func() {
+			requested = nil
+			offset = 42
+		})
+		It("returns explicit offsets", func() {
+			position := &webhook.StartPosition{Offsets: map[int32]int64{1: 7}}
+			Expect(position.Resolve("topic", 1, getOffset)).To(Equal(int64(7)))
+			Expect(requested).To(BeEmpty())
+		})
+		It("looks up offsets by time in milliseconds", func() {
+			position := &webhook.StartPosition{Initial: sarama.OffsetNewest, Time: time.Unix(1538395200, 0)}
+			Expect(position.Resolve("topic", 0, getOffset)).To(Equal(int64(42)))
+			Expect(requested).To(Equal([]int64{1538395200000}))
+		})
+		It("falls back to newest if no record is after the time", func() {
+			offset = -1
+			position := &webhook.StartPosition{Initial: sarama.OffsetNewest, Time: time.Unix(1538395200, 0)}
+			_, err := position.Resolve("topic", 0, getOffset)
+			Expect(err).To(BeNil())
+			Expect(requested).To(Equal([]int64{1538395200000, sarama.OffsetNewest}))
+		})
+		It("looks up the initial offset of unlisted partitions", func() {
+			position := &webhook.StartPosition{Initial: sarama.OffsetOldest, Offsets: map[int32]int64{1: 7}}
+			Expect(position.Resolve("topic", 0, getOffset)).To(Equal(int64(42)))
+			Expect(requested).To(Equal([]int64{sarama.OffsetOldest}))
+		})
+		It("returns lookup errors", func() {
+			position := &webhook.StartPosition{Initial: sarama.OffsetOldest}
+			_, err := position.Resolve("topic", 0, func(topic string, partition int32, time int64) (int64, error) {
+				return 0, errors.New("banana")
+			})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
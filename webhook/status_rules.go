@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Classification defines how a failed delivery is handled.
+type Classification string
+
+const (
+	// ClassificationRetry retries the delivery after the normal backoff.
+	ClassificationRetry Classification = "retry"
+	// ClassificationRetryAfter retries the delivery after the time defined by the Retry-After header or the normal backoff if missing.
+	ClassificationRetryAfter Classification = "retry-after"
+	// ClassificationPermanent skips all retries.
+	ClassificationPermanent Classification = "permanent"
+)
+
+// DefaultStatusRules treats 4xx as permanent, except 408 and 429.
+const DefaultStatusRules = "408:retry,429:retry-after,503:retry-after,400-499:permanent"
+
+// StatusRule classifies all status codes between From and To inclusive.
+type StatusRule struct {
+	From           int
+	To             int
+	Classification Classification
+}
+
+func (s StatusRule) String() string {
+	if s.From == s.To {
+		return fmt.Sprintf("%d:%s", s.From, s.Classification)
+	}
+	return fmt.Sprintf("%d-%d:%s", s.From, s.To, s.Classification)
+}
+
+// StatusRules classifies status codes, the first matching rule wins.
+type StatusRules []StatusRule
+
+// ParseStatusRules parses a comma separated list of rules like "408:retry,400-499:permanent".
+func ParseStatusRules(value string) (StatusRules, error) {
+	var result StatusRules
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pos := strings.LastIndex(part, ":")
+		if pos == -1 {
+			return nil, errors.Errorf("classification of status rule %s missing", part)
+		}
+		rule := StatusRule{
+			Classification: Classification(part[pos+1:]),
+		}
+		switch rule.Classification {
+		case ClassificationRetry, ClassificationRetryAfter, ClassificationPermanent:
+		default:
+			return nil, errors.Errorf("unknown classification %s in status rule %s", rule.Classification, part)
+		}
+		codes := strings.SplitN(part[:pos], "-", 2)
+		from, err := strconv.Atoi(codes[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse status code of rule %s failed", part)
+		}
+		rule.From, rule.To = from, from
+		if len(codes) == 2 {
+			if rule.To, err = strconv.Atoi(codes[1]); err != nil {
+				return nil, errors.Wrapf(err, "parse status code of rule %s failed", part)
+			}
+		}
+		if rule.From < 100 || rule.To > 599 || rule.From > rule.To {
+			return nil, errors.Errorf("invalid status range in rule %s", part)
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// Classify returns the classification of the first matching rule. Status codes without rule are retried.
+func (s StatusRules) Classify(statusCode int) Classification {
+	for _, rule := range s {
+		if rule.From <= statusCode && statusCode <= rule.To {
+			return rule.Classification
+		}
+	}
+	return ClassificationRetry
+}
+
+// ClassifyError returns the classification of the given error. Errors without status, like network errors, are retried.
+// Failed transformations are permanent.
+func (s StatusRules) ClassifyError(err error) Classification {
+	for _, cause := range causes(err) {
+		if _, ok := cause.(*TransformError); ok {
+			return ClassificationPermanent
+		}
+	}
+	statusError := statusErrorOf(err)
+	if statusError == nil {
+		return ClassificationRetry
+	}
+	return s.Classify(statusError.StatusCode)
+}
+
+func (s StatusRules) String() string {
+	parts := make([]string, len(s))
+	for i, rule := range s {
+		parts[i] = rule.String()
+	}
+	return strings.Join(parts, ",")
+}
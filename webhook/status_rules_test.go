@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("StatusRules", func() {
+	var statusRules webhook.StatusRules
+	BeforeEach(func() {
+		var err error
+		statusRules, err = webhook.ParseStatusRules(webhook.DefaultStatusRules)
+		Expect(err).To(BeNil())
+	})
+	It("parses rules", func() {
+		Expect(statusRules).To(Equal(webhook.StatusRules{
+			{From: 408, To: 408, Classification: webhook.ClassificationRetry},
+			{From: 429, To: 429, Classification: webhook.ClassificationRetryAfter},
+			{From: 503, To: 503, Classification: webhook.ClassificationRetryAfter},
+			{From: 400, To: 499, Classification: webhook.ClassificationPermanent},
+		}))
+		Expect(statusRules.String()).To(Equal(webhook.DefaultStatusRules))
+	})
+	It("parses empty rules", func() {
+		rules, err := webhook.ParseStatusRules("")
+		Expect(err).To(BeNil())
+		Expect(rules).To(BeEmpty())
+	})
+	It("returns error for invalid rules", func() {
+		for _, value := range []string{"400", "400:ignore", "abc:retry", "499-400:retry", "400-700:retry"} {
+			_, err := webhook.ParseStatusRules(value)
+			Expect(err).To(HaveOccurred(), value)
+		}
+	})
+	It("classifies status codes", func() {
+		Expect(statusRules.Classify(400)).To(Equal(webhook.ClassificationPermanent))
+		Expect(statusRules.Classify(404)).To(Equal(webhook.ClassificationPermanent))
+		Expect(statusRules.Classify(408)).To(Equal(webhook.ClassificationRetry))
+		Expect(statusRules.Classify(429)).To(Equal(webhook.ClassificationRetryAfter))
+		Expect(statusRules.Classify(500)).To(Equal(webhook.ClassificationRetry))
+		Expect(statusRules.Classify(503)).To(Equal(webhook.ClassificationRetryAfter))
+	})
+	It("retries errors without status", func() {
+		Expect(statusRules.ClassifyError(errors.New("connection refused"))).To(Equal(webhook.ClassificationRetry))
+	})
+	It("classifies wrapped status errors", func() {
+		err := errors.Wrap(&webhook.StatusError{StatusCode: 400}, "request failed")
+		Expect(statusRules.ClassifyError(err)).To(Equal(webhook.ClassificationPermanent))
+	})
+	It("never retries failed transformations", func() {
+		err := errors.Wrap(&webhook.TransformError{Err: errors.New("banana")}, "build request failed")
+		Expect(statusRules.ClassifyError(err)).To(Equal(webhook.ClassificationPermanent))
+	})
+})
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTopicRefreshInterval is the interval topics matching a pattern are looked up.
+const DefaultTopicRefreshInterval = time.Minute
+
+// TopicSelector selects the topics to subscribe by name and by regular expression.
+type TopicSelector struct {
+	Names []string
+	// Pattern matches whole topic names, internal topics starting with __ are never matched
+	Pattern *regexp.Regexp
+	// pattern as given, without anchors
+	pattern string
+}
+
+// NewTopicSelector parses a comma separated list of topic names and a regular expression, both may be empty.
+func NewTopicSelector(names string, pattern string) (*TopicSelector, error) {
+	selector := &TopicSelector{
+		Names:   splitList(names),
+		pattern: pattern,
+	}
+	if pattern != "" {
+		var err error
+		selector.Pattern, err = regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "compile topic pattern %s failed", pattern)
+		}
+	}
+	return selector, nil
+}
+
+// Empty returns true if neither names nor a pattern is given.
+func (t *TopicSelector) Empty() bool {
+	return len(t.Names) == 0 && t.Pattern == nil
+}
+
+// Dynamic returns true if the selected topics change with the topics of the cluster.
+func (t *TopicSelector) Dynamic() bool {
+	return t.Pattern != nil
+}
+
+// Select returns the sorted names and all available topics matching the pattern.
+func (t *TopicSelector) Select(available []string) []string {
+	selected := make(map[string]bool)
+	for _, name := range t.Names {
+		selected[name] = true
+	}
+	if t.Pattern != nil {
+		for _, topic := range available {
+			if !strings.HasPrefix(topic, "__") && t.Pattern.MatchString(topic) {
+				selected[topic] = true
+			}
+		}
+	}
+	topics := make([]string, 0, len(selected))
+	for topic := range selected {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Overlaps returns true if both selectors may select the same topic. Two patterns always overlap,
+// because the topics they select are only known at runtime.
+func (t *TopicSelector) Overlaps(other *TopicSelector) bool {
+	if t.Pattern != nil && other.Pattern != nil {
+		return true
+	}
+	for _, name := range other.Names {
+		if t.selects(name) {
+			return true
+		}
+	}
+	for _, name := range t.Names {
+		if other.selects(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TopicSelector) selects(topic string) bool {
+	for _, name := range t.Names {
+		if name == topic {
+			return true
+		}
+	}
+	return t.Pattern != nil && !strings.HasPrefix(topic, "__") && t.Pattern.MatchString(topic)
+}
+
+func (t *TopicSelector) String() string {
+	if t.Pattern == nil {
+		return strings.Join(t.Names, ",")
+	}
+	if len(t.Names) == 0 {
+		return fmt.Sprintf("/%s/", t.pattern)
+	}
+	return fmt.Sprintf("%s,/%s/", strings.Join(t.Names, ","), t.pattern)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TopicSelector", func() {
+	available := []string{"__consumer_offsets", "invoices", "orders.eu", "orders.us", "preorders.eu"}
+	It("selects names", func() {
+		selector, err := webhook.NewTopicSelector("orders, invoices", "")
+		Expect(err).To(BeNil())
+		Expect(selector.Dynamic()).To(BeFalse())
+		Expect(selector.Select(nil)).To(Equal([]string{"invoices", "orders"}))
+		Expect(selector.String()).To(Equal("orders,invoices"))
+	})
+	It("selects whole topic names matching the pattern", func() {
+		selector, err := webhook.NewTopicSelector("", `orders\..*`)
+		Expect(err).To(BeNil())
+		Expect(selector.Dynamic()).To(BeTrue())
+		Expect(selector.Select(available)).To(Equal([]string{"orders.eu", "orders.us"}))
+		Expect(selector.String()).To(Equal(`/orders\..*/`))
+	})
+	It("combines names and pattern", func() {
+		selector, err := webhook.NewTopicSelector("invoices,orders.eu", `orders\..*`)
+		Expect(err).To(BeNil())
+		Expect(selector.Select(available)).To(Equal([]string{"invoices", "orders.eu", "orders.us"}))
+	})
+	It("never selects internal topics by pattern", func() {
+		selector, err := webhook.NewTopicSelector("", ".*")
+		Expect(err).To(BeNil())
+		Expect(selector.Select(available)).NotTo(ContainElement("__consumer_offsets"))
+	})
+	It("is empty without names and pattern", func() {
+		selector, err := webhook.NewTopicSelector(" , ", "")
+		Expect(err).To(BeNil())
+		Expect(selector.Empty()).To(BeTrue())
+	})
+	It("returns error for invalid pattern", func() {
+		_, err := webhook.NewTopicSelector("", "orders(")
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Transform renders the outgoing body and extra headers of a message with text/template.
+//
+//	{"text": {{ toJSON (printf "order %s: %v" .Key (field (fromJSON .Value) "amount")) }}}
+//
+// Templates get a TransformData and the functions fromJSON, toJSON, field, b64enc, b64dec, formatTime and unixMillis.
+type Transform struct {
+	// Body renders the body, the message value is sent if nil
+	Body *template.Template
+	// Headers render additional request headers by name
+	Headers map[string]*template.Template
+}
+
+// TransformData is passed to the templates of a Transform.
+type TransformData struct {
+	Key       string
+	Value     string
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Headers   map[string]string
+}
+
+// NewTransform parses the body template and the header templates. Returns nil if both are empty.
+func NewTransform(body string, headers map[string]string) (*Transform, error) {
+	if body == "" && len(headers) == 0 {
+		return nil, nil
+	}
+	transform := &Transform{
+		Headers: make(map[string]*template.Template, len(headers)),
+	}
+	if body != "" {
+		tmpl, err := template.New("body").Funcs(transformFuncs).Option("missingkey=error").Parse(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse body template failed")
+		}
+		transform.Body = tmpl
+	}
+	for name, value := range headers {
+		if name == "" {
+			return nil, errors.New("header name missing")
+		}
+		tmpl, err := template.New(name).Funcs(transformFuncs).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse template of header %s failed", name)
+		}
+		transform.Headers[http.CanonicalHeaderKey(name)] = tmpl
+	}
+	return transform, nil
+}
+
+// Apply returns a copy of the message with the rendered body as value and the rendered headers.
+func (t *Transform) Apply(msg *sarama.ConsumerMessage) (*sarama.ConsumerMessage, http.Header, error) {
+	data := NewTransformData(msg)
+	result := *msg
+	if t.Body != nil {
+		body, err := execute(t.Body, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		result.Value = []byte(body)
+	}
+	header := make(http.Header, len(t.Headers))
+	names := make([]string, 0, len(t.Headers))
+	for name := range t.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := execute(t.Headers[name], data)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set(name, strings.TrimSpace(value))
+	}
+	return &result, header, nil
+}
+
+// NewTransformData returns the template data of the message, the last value of repeated headers wins.
+func NewTransformData(msg *sarama.ConsumerMessage) TransformData {
+	data := TransformData{
+		Key:       string(msg.Key),
+		Value:     string(msg.Value),
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+		Headers:   make(map[string]string, len(msg.Headers)),
+	}
+	for _, header := range msg.Headers {
+		if header != nil {
+			data.Headers[string(header.Key)] = string(header.Value)
+		}
+	}
+	return data
+}
+
+func execute(tmpl *template.Template, data TransformData) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", &TransformError{Err: errors.Wrapf(err, "execute template %s failed", tmpl.Name())}
+	}
+	return buf.String(), nil
+}
+
+var transformFuncs = template.FuncMap{
+	// fromJSON parses a JSON document, e.g. the message value
+	"fromJSON": func(value string) (interface{}, error) {
+		decoder := json.NewDecoder(strings.NewReader(value))
+		decoder.UseNumber()
+		var result interface{}
+		if err := decoder.Decode(&result); err != nil {
+			return nil, errors.Wrap(err, "parse json failed")
+		}
+		return result, nil
+	},
+	// toJSON returns the value as JSON, strings quoted and escaped
+	"toJSON": func(value interface{}) (string, error) {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return "", errors.Wrap(err, "marshal json failed")
+		}
+		return string(content), nil
+	},
+	// field returns the value at the dot separated path of a parsed JSON document, nil if missing
+	"field": func(value interface{}, path string) interface{} {
+		for _, segment := range strings.Split(path, ".") {
+			switch node := value.(type) {
+			case map[string]interface{}:
+				value = node[segment]
+			case []interface{}:
+				index, err := strconv.Atoi(segment)
+				if err != nil || index < 0 || index >= len(node) {
+					return nil
+				}
+				value = node[index]
+			default:
+				return nil
+			}
+		}
+		return value
+	},
+	"b64enc": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+	"b64dec": func(value string) (string, error) {
+		content, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", errors.Wrap(err, "decode base64 failed")
+		}
+		return string(content), nil
+	},
+	// formatTime formats the time with the Go layout, e.g. 2006-01-02T15:04:05Z07:00, in UTC
+	"formatTime": func(layout string, t time.Time) string {
+		return t.UTC().Format(layout)
+	},
+	"unixMillis": func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	},
+}
+
+// TransformRequestBuilder transforms messages before the RequestBuilder encodes them.
+type TransformRequestBuilder struct {
+	Transform *Transform
+	// ContentType of the rendered body, set if the RequestBuilder sets none
+	ContentType    string
+	RequestBuilder interface {
+		Encode(msg *sarama.ConsumerMessage) (*http.Request, error)
+	}
+}
+
+func (t *TransformRequestBuilder) Encode(msg *sarama.ConsumerMessage) (*http.Request, error) {
+	transformed, header, err := t.Transform.Apply(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.RequestBuilder.Encode(transformed)
+	if err != nil {
+		return nil, err
+	}
+	if t.ContentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", t.ContentType)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return req, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transform", func() {
+	var msg *sarama.ConsumerMessage
+	BeforeEach(func() {
+		msg = &sarama.ConsumerMessage{
+			Topic:     "orders",
+			Partition: 2,
+			Offset:    42,
+			Key:       []byte("order-1"),
+			Value:     []byte(`{"amount":150,"customer":{"name":"Ann \"A\""},"items":[{"sku":"a"}]}`),
+			Timestamp: time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC),
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("type"), Value: []byte("order.created")},
+			},
+		}
+	})
+	apply := func(body string, headers map[string]string) (*sarama.ConsumerMessage, http.Header, error) {
+		transform, err := webhook.NewTransform(body, headers)
+		Expect(err).To(BeNil())
+		return transform.Apply(msg)
+	}
+	It("returns nil without templates", func() {
+		transform, err := webhook.NewTransform("", nil)
+		Expect(err).To(BeNil())
+		Expect(transform).To(BeNil())
+	})
+	It("renders the body from key, value, headers and metadata", func() {
+		result, _, err := apply(`{{ .Topic }}/{{ .Partition }}/{{ .Offset }} {{ .Key }} {{ index .Headers "type" }} {{ formatTime "2006-01-02" .Timestamp }} {{ unixMillis .Timestamp }}`, nil)
+		Expect(err).To(BeNil())
+		Expect(string(result.Value)).To(Equal("orders/2/42 order-1 order.created 2018-10-01 1538395200000"))
+		Expect(string(msg.Value)).To(HavePrefix(`{"amount"`))
+	})
+	It("extracts json fields", func() {
+		result, _, err := apply(`{{ $v := fromJSON .Value }}{"text":{{ toJSON (printf "%s paid %v for %v" (field $v "customer.name") (field $v "amount") (field $v "items.0.sku")) }},"missing":{{ toJSON (field $v "items.3.sku") }}}`, nil)
+		Expect(err).To(BeNil())
+		Expect(string(result.Value)).To(Equal(`{"text":"Ann \"A\" paid 150 for a","missing":null}`))
+	})
+	It("encodes and decodes base64", func() {
+		result, _, err := apply(`{{ b64enc .Key }} {{ b64dec "YmFuYW5h" }}`, nil)
+		Expect(err).To(BeNil())
+		Expect(string(result.Value)).To(Equal("b3JkZXItMQ== banana"))
+	})
+	It("renders headers and keeps the value without body template", func() {
+		result, header, err := apply("", map[string]string{"x-event": "{{ .Topic }}.{{ index .Headers \"type\" }}\n"})
+		Expect(err).To(BeNil())
+		Expect(result.Value).To(Equal(msg.Value))
+		Expect(header.Get("X-Event")).To(Equal("orders.order.created"))
+	})
+	It("returns a TransformError if the template fails", func() {
+		msg.Value = []byte("banana")
+		_, _, err := apply(`{{ fromJSON .Value }}`, nil)
+		Expect(err).To(BeAssignableToTypeOf(&webhook.TransformError{}))
+		_, _, err = apply(`{{ .Missing }}`, nil)
+		Expect(err).NotTo(BeNil())
+	})
+	It("returns error for invalid templates", func() {
+		_, err := webhook.NewTransform("{{ .Value", nil)
+		Expect(err).NotTo(BeNil())
+		_, err = webhook.NewTransform("", map[string]string{"X-Event": "{{ unknown }}"})
+		Expect(err).NotTo(BeNil())
+	})
+	Context("TransformRequestBuilder", func() {
+		var builder *webhook.TransformRequestBuilder
+		BeforeEach(func() {
+			transform, err := webhook.NewTransform(`{"text":{{ toJSON .Key }}}`, map[string]string{"X-Event": "{{ .Topic }}"})
+			Expect(err).To(BeNil())
+			builder = &webhook.TransformRequestBuilder{
+				Transform:   transform,
+				ContentType: "application/json",
+				RequestBuilder: &webhook.RequestCoding{
+					Url:    "http://example.com",
+					Method: http.MethodPost,
+					Signer: &webhook.Signer{Secret: "secret"},
+				},
+			}
+		})
+		It("encodes the rendered body with content type and headers", func() {
+			req, err := builder.Encode(msg)
+			Expect(err).To(BeNil())
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal(`{"text":"order-1"}`))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(req.Header.Get("X-Event")).To(Equal("orders"))
+			Expect(req.Header.Get(webhook.TopicField)).To(Equal("orders"))
+		})
+		It("signs the rendered body", func() {
+			req, err := builder.Encode(msg)
+			Expect(err).To(BeNil())
+			decoded, err := (&webhook.RequestCoding{Signer: &webhook.Signer{Secret: "secret"}}).Decode(req)
+			Expect(err).To(BeNil())
+			Expect(string(decoded.Value)).To(Equal(`{"text":"order-1"}`))
+		})
+	})
+})
@@ -0,0 +1,198 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// urlTemplatePlaceholder is passed as url to request builders wrapped by a URLRequestBuilder, which replaces it.
+const urlTemplatePlaceholder = "http://localhost/"
+
+// IsURLTemplate returns true if the url contains template actions.
+func IsURLTemplate(rawurl string) bool {
+	return strings.Contains(rawurl, "{{")
+}
+
+// URLTemplate renders the hook url per message with the data and functions of a Transform.
+//
+//	https://api.example.com/customers/{{ .Key }}/events?type={{ field (fromJSON .Value) "type" }}
+//
+// The output of every action is escaped for its position, a path segment or a query value, so records
+// can not add path segments or parameters. The rendered url must use http or https and an allowed host.
+type URLTemplate struct {
+	tmpl         *template.Template
+	allowedHosts []string
+}
+
+// NewURLTemplate parses the url template. allowedHosts is a comma separated list of hosts, host:port or *.domain.
+// If empty, only the host written in front of the first action is allowed.
+func NewURLTemplate(rawurl string, allowedHosts string) (*URLTemplate, error) {
+	tmpl, err := template.New("url").Funcs(transformFuncs).Funcs(template.FuncMap{
+		urlEscapers[urlPartHost]:  escapeURLPathSegment,
+		urlEscapers[urlPartPath]:  escapeURLPathSegment,
+		urlEscapers[urlPartQuery]: escapeURLQuery,
+	}).Option("missingkey=error").Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url template failed")
+	}
+	part := urlPartHost
+	escapeActions(tmpl.Tree, tmpl.Tree.Root, &part)
+	result := &URLTemplate{
+		tmpl:         tmpl,
+		allowedHosts: splitList(strings.ToLower(allowedHosts)),
+	}
+	if len(result.allowedHosts) == 0 {
+		prefix := rawurl
+		if index := strings.Index(rawurl, "{{"); index >= 0 {
+			prefix = rawurl[:index]
+		}
+		u, err := url.Parse(prefix)
+		if err != nil || u.Host == "" || u.Path == "" && u.RawQuery == "" && !strings.HasSuffix(prefix, "?") {
+			return nil, errors.Errorf("host of url template %s is not fixed, allowed hosts required", rawurl)
+		}
+		result.allowedHosts = []string{strings.ToLower(u.Host)}
+	}
+	return result, nil
+}
+
+// Render returns the url of the message. Failures are TransformErrors.
+func (u *URLTemplate) Render(msg *sarama.ConsumerMessage) (*url.URL, error) {
+	rawurl, err := execute(u.tmpl, NewTransformData(msg))
+	if err != nil {
+		return nil, err
+	}
+	result, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, &TransformError{Err: errors.Wrapf(err, "parse url %s failed", rawurl)}
+	}
+	if result.Scheme != "http" && result.Scheme != "https" {
+		return nil, &TransformError{Err: errors.Errorf("scheme of url %s not allowed", rawurl)}
+	}
+	if result.User != nil || !u.allowed(result) {
+		return nil, &TransformError{Err: errors.Errorf("host of url %s not allowed", rawurl)}
+	}
+	for _, segment := range strings.Split(result.EscapedPath(), "/") {
+		if segment, _ = url.PathUnescape(segment); segment == "." || segment == ".." {
+			return nil, &TransformError{Err: errors.Errorf("path of url %s contains dot segments", rawurl)}
+		}
+	}
+	return result, nil
+}
+
+func (u *URLTemplate) allowed(target *url.URL) bool {
+	host := strings.ToLower(target.Host)
+	hostname := strings.ToLower(target.Hostname())
+	for _, allowed := range u.allowedHosts {
+		switch {
+		case allowed == host || allowed == hostname:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(hostname, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// URLRequestBuilder replaces the url of requests built by the RequestBuilder with the rendered URLTemplate.
+// Signatures do not cover the url, so requests stay valid.
+type URLRequestBuilder struct {
+	URL            *URLTemplate
+	RequestBuilder interface {
+		Encode(msg *sarama.ConsumerMessage) (*http.Request, error)
+	}
+}
+
+func (u *URLRequestBuilder) Encode(msg *sarama.ConsumerMessage) (*http.Request, error) {
+	target, err := u.URL.Render(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := u.RequestBuilder.Encode(msg)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = target
+	req.Host = target.Host
+	return req, nil
+}
+
+type urlPart int
+
+const (
+	urlPartHost urlPart = iota
+	urlPartPath
+	urlPartQuery
+)
+
+var urlEscapers = map[urlPart]string{
+	urlPartHost:  "_urlHost",
+	urlPartPath:  "_urlPath",
+	urlPartQuery: "_urlQuery",
+}
+
+// escapeActions appends the escaper of the url part to every action printing a value.
+// The part is tracked through the text in front of the actions.
+func escapeActions(tree *parse.Tree, node parse.Node, part *urlPart) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeActions(tree, child, part)
+		}
+	case *parse.TextNode:
+		text := string(n.Text)
+		if *part == urlPartHost {
+			// the host ends at the first slash behind the scheme
+			if index := strings.Index(text, "://"); index >= 0 {
+				text = text[index+3:]
+			}
+			if index := strings.IndexAny(text, "/?"); index >= 0 {
+				*part = urlPartPath
+				text = text[index:]
+			}
+		}
+		if *part == urlPartPath && strings.Contains(text, "?") {
+			*part = urlPartQuery
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		escaper := parse.NewIdentifier(urlEscapers[*part]).SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{escaper},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, n.List, part)
+		escapeActions(tree, n.ElseList, part)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List, part)
+		escapeActions(tree, n.ElseList, part)
+	case *parse.WithNode:
+		escapeActions(tree, n.List, part)
+		escapeActions(tree, n.ElseList, part)
+	}
+}
+
+func escapeURLPathSegment(value interface{}) string {
+	return url.PathEscape(fmt.Sprint(value))
+}
+
+func escapeURLQuery(value interface{}) string {
+	return url.QueryEscape(fmt.Sprint(value))
+}
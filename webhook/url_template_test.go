@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook_test
+
+import (
+	"net/http"
+
+	"github.com/Shopify/sarama"
+	"github.com/bborbe/kafka-webhook/webhook"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("URLTemplate", func() {
+	var msg *sarama.ConsumerMessage
+	BeforeEach(func() {
+		msg = &sarama.ConsumerMessage{
+			Topic: "orders",
+			Key:   []byte("c1"),
+			Value: []byte(`{"type":"created","tenant":"eu"}`),
+		}
+	})
+	render := func(rawurl string, allowedHosts string) (string, error) {
+		tmpl, err := webhook.NewURLTemplate(rawurl, allowedHosts)
+		Expect(err).To(BeNil())
+		u, err := tmpl.Render(msg)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	}
+	It("detects templates", func() {
+		Expect(webhook.IsURLTemplate("https://api/customers/{{.Key}}/events")).To(BeTrue())
+		Expect(webhook.IsURLTemplate("https://api/events")).To(BeFalse())
+	})
+	It("renders key and json fields", func() {
+		Expect(render(`https://api/customers/{{ .Key }}/events?type={{ field (fromJSON .Value) "type" }}`, "")).To(Equal("https://api/customers/c1/events?type=created"))
+	})
+	It("escapes path segments", func() {
+		msg.Key = []byte("../admin?x=1#y")
+		Expect(render(`https://api/customers/{{ .Key }}/events`, "")).To(Equal("https://api/customers/..%2Fadmin%3Fx=1%23y/events"))
+	})
+	It("escapes query values", func() {
+		msg.Key = []byte("a&admin=true")
+		Expect(render(`https://api/events?customer={{ .Key }}`, "")).To(Equal("https://api/events?customer=a%26admin%3Dtrue"))
+	})
+	It("rejects dot segments", func() {
+		msg.Key = []byte("..")
+		_, err := render(`https://api/customers/{{ .Key }}/events`, "")
+		Expect(err).To(BeAssignableToTypeOf(&webhook.TransformError{}))
+	})
+	It("renders hosts of the allowed list", func() {
+		Expect(render(`https://{{ field (fromJSON .Value) "tenant" }}.api.example.com/events`, "*.api.example.com")).To(Equal("https://eu.api.example.com/events"))
+		Expect(render(`https://{{ field (fromJSON .Value) "tenant" }}.api.example.com/events`, "eu.api.example.com")).To(Equal("https://eu.api.example.com/events"))
+	})
+	It("rejects hosts not allowed", func() {
+		msg.Value = []byte(`{"tenant":"evil.com/"}`)
+		_, err := render(`https://{{ field (fromJSON .Value) "tenant" }}.api.example.com/events`, "*.api.example.com")
+		Expect(err).To(BeAssignableToTypeOf(&webhook.TransformError{}))
+		msg.Value = []byte(`{"tenant":"evil.com@eu"}`)
+		_, err = render(`https://{{ field (fromJSON .Value) "tenant" }}.api.example.com/events`, "*.api.example.com")
+		Expect(err).To(BeAssignableToTypeOf(&webhook.TransformError{}))
+	})
+	It("requires allowed hosts if the host is templated", func() {
+		_, err := webhook.NewURLTemplate(`https://{{ .Key }}.example.com/events`, "")
+		Expect(err).NotTo(BeNil())
+		_, err = webhook.NewURLTemplate(`https://api.example.com{{ .Key }}`, "")
+		Expect(err).NotTo(BeNil())
+		_, err = webhook.NewURLTemplate(`{{ .Key }}`, "")
+		Expect(err).NotTo(BeNil())
+	})
+	It("returns error for invalid templates", func() {
+		_, err := webhook.NewURLTemplate(`https://api/{{ .Key`, "")
+		Expect(err).NotTo(BeNil())
+	})
+	It("replaces the url of requests", func() {
+		tmpl, err := webhook.NewURLTemplate(`https://api/customers/{{ .Key }}/events`, "")
+		Expect(err).To(BeNil())
+		builder := &webhook.URLRequestBuilder{
+			URL: tmpl,
+			RequestBuilder: &webhook.RequestCoding{
+				Url:    "http://localhost/",
+				Method: http.MethodPut,
+				Signer: &webhook.Signer{Secret: "secret"},
+			},
+		}
+		req, err := builder.Encode(msg)
+		Expect(err).To(BeNil())
+		Expect(req.Method).To(Equal(http.MethodPut))
+		Expect(req.URL.String()).To(Equal("https://api/customers/c1/events"))
+		Expect(req.Host).To(Equal("api"))
+		Expect(req.Header.Get(webhook.SignaturField)).NotTo(BeEmpty())
+	})
+})